@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+
+	"github.com/fluxcd/pkg/git/github"
+)
+
+// Operation is the kind of remote operation credentials are requested for.
+type Operation string
+
+const (
+	// OperationRead is an operation which reads from the remote, for
+	// example a clone or fetch.
+	OperationRead Operation = "read"
+	// OperationWrite is an operation which writes to the remote, for
+	// example a push.
+	OperationWrite Operation = "write"
+)
+
+// Credentials are the credentials for an HTTP(S) Git transport. If both are
+// set, basic auth takes precedence over the bearer token.
+type Credentials struct {
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+// CredentialsRequest describes the operation credentials are requested for.
+type CredentialsRequest struct {
+	// URL is the URL of the remote repository.
+	URL string
+	// Operation is the kind of operation that is performed on the remote.
+	Operation Operation
+	// Refresh indicates that the credentials previously returned for the
+	// request were rejected by the remote, and should be renewed instead
+	// of being served from a cache.
+	Refresh bool
+}
+
+// CredentialsProvider supplies the credentials for HTTP(S) Git transports
+// on demand. Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	// Credentials returns the credentials for the given request. It may
+	// return nil if no credentials are required.
+	Credentials(ctx context.Context, req CredentialsRequest) (*Credentials, error)
+}
+
+// StaticCredentialsProvider is a CredentialsProvider which always returns
+// the same credentials.
+type StaticCredentialsProvider Credentials
+
+// Credentials returns the static credentials, regardless of the request.
+func (p StaticCredentialsProvider) Credentials(_ context.Context, _ CredentialsRequest) (*Credentials, error) {
+	c := Credentials(p)
+	return &c, nil
+}
+
+// GitHubAppCredentialsProvider is a CredentialsProvider which returns the
+// installation access token of a GitHub App.
+type GitHubAppCredentialsProvider struct {
+	// Opts configures the client which mints the tokens.
+	Opts []github.OptFunc
+}
+
+// Credentials returns an installation access token for the configured
+// GitHub App installation, which is cached until shortly before it expires.
+// If the request is a refresh, a new token is minted.
+func (p GitHubAppCredentialsProvider) Credentials(ctx context.Context, req CredentialsRequest) (*Credentials, error) {
+	client, err := github.New(p.Opts...)
+	if err != nil {
+		return nil, err
+	}
+	if req.Refresh {
+		client.InvalidateToken()
+	}
+	token, err := client.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{
+		Username: github.AccessTokenUsername,
+		Password: token.Token,
+	}, nil
+}
+
+// Provider returns the CredentialsProvider for the options. This is the
+// configured CredentialsProvider, the provider configured by ProviderOpts,
+// or a StaticCredentialsProvider with the Username, Password and
+// BearerToken of the options, in that order.
+func (o AuthOptions) Provider() CredentialsProvider {
+	if o.CredentialsProvider != nil {
+		return o.CredentialsProvider
+	}
+	if o.ProviderOpts != nil && o.ProviderOpts.Name == ProviderGitHub {
+		return GitHubAppCredentialsProvider{Opts: o.ProviderOpts.GitHubOpts}
+	}
+	return StaticCredentialsProvider{
+		Username:    o.Username,
+		Password:    o.Password,
+		BearerToken: o.BearerToken,
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git/github"
+)
+
+type fakeCredentialsProvider struct{}
+
+func (fakeCredentialsProvider) Credentials(_ context.Context, _ CredentialsRequest) (*Credentials, error) {
+	return nil, nil
+}
+
+func TestAuthOptions_Provider(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AuthOptions
+		want CredentialsProvider
+	}{
+		{
+			name: "static credentials",
+			opts: AuthOptions{Username: "user", Password: "pass", BearerToken: "token"},
+			want: StaticCredentialsProvider{Username: "user", Password: "pass", BearerToken: "token"},
+		},
+		{
+			name: "github provider",
+			opts: AuthOptions{
+				Username:     "user",
+				ProviderOpts: &ProviderOptions{Name: ProviderGitHub, GitHubOpts: []github.OptFunc{github.WithAppID("123")}},
+			},
+			want: GitHubAppCredentialsProvider{Opts: []github.OptFunc{github.WithAppID("123")}},
+		},
+		{
+			name: "custom provider",
+			opts: AuthOptions{
+				Username:            "user",
+				ProviderOpts:        &ProviderOptions{Name: ProviderGitHub},
+				CredentialsProvider: fakeCredentialsProvider{},
+			},
+			want: fakeCredentialsProvider{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got := tt.opts.Provider()
+			g.Expect(got).To(BeAssignableToTypeOf(tt.want))
+			if static, ok := tt.want.(StaticCredentialsProvider); ok {
+				g.Expect(got).To(Equal(static))
+			}
+		})
+	}
+}
+
+func TestStaticCredentialsProvider_Credentials(t *testing.T) {
+	g := NewWithT(t)
+
+	p := StaticCredentialsProvider{Username: "user", Password: "pass"}
+	creds, err := p.Credentials(context.TODO(), CredentialsRequest{Refresh: true})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(creds).To(Equal(&Credentials{Username: "user", Password: "pass"}))
+
+	// The returned credentials are a copy.
+	creds.Password = "changed"
+	g.Expect(p.Password).To(Equal("pass"))
+}
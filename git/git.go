@@ -17,18 +17,17 @@ limitations under the License.
 package git
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
-
-	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
 const (
 	// HashTypeSHA1 is the SHA1 hash algorithm.
 	HashTypeSHA1 = "sha1"
+	// HashTypeSHA256 is the SHA256 hash algorithm.
+	HashTypeSHA256 = "sha256"
 	// HashTypeUnknown is an unknown hash algorithm.
 	HashTypeUnknown = "<unknown>"
 )
@@ -43,6 +42,8 @@ func (h Hash) Algorithm() string {
 	switch len(h) {
 	case 40:
 		return HashTypeSHA1
+	case 64:
+		return HashTypeSHA256
 	default:
 		return HashTypeUnknown
 	}
@@ -81,7 +82,7 @@ type Commit struct {
 	// Committer is the one performing the commit, might be different from
 	// Author.
 	Committer Signature
-	// Signature is the PGP signature of the commit.
+	// Signature is the PGP, SSH or X.509 signature of the commit.
 	Signature string
 	// Encoded is the encoded commit, without any signature.
 	Encoded []byte
@@ -126,6 +127,33 @@ func (c *Commit) Verify(keyRings ...string) (string, error) {
 	return fingerprint, nil
 }
 
+// VerifySSH verifies the SSH Signature of the commit with the given allowed
+// signers. Each allowed signers entry is expected to be in the format of an
+// 'gpg.ssh.allowedSignersFile', as described in the "ALLOWED SIGNERS"
+// section of ssh-keygen(1).
+// It returns the SHA256 fingerprint of the key the signature was verified
+// with, or an error. Like Verify, it does not verify the signature of the
+// referencing tag (if present).
+func (c *Commit) VerifySSH(allowedSigners ...string) (string, error) {
+	result, err := verifySSHSignature(c.Signature, c.Encoded, allowedSigners...)
+	if err != nil {
+		return "", fmt.Errorf("unable to verify Git commit: %w", err)
+	}
+	return result.Fingerprint, nil
+}
+
+// VerifyX509 verifies the X.509 (S/MIME) Signature of the commit with the
+// given options. It returns the subject of the certificate the signature was
+// verified with, or an error. Like Verify, it does not verify the signature
+// of the referencing tag (if present).
+func (c *Commit) VerifyX509(opts X509VerifyOptions) (string, error) {
+	result, err := verifyX509Signature(c.Signature, c.Encoded, opts)
+	if err != nil {
+		return "", fmt.Errorf("unable to verify Git commit: %w", err)
+	}
+	return result.Identity, nil
+}
+
 // ShortMessage returns the first 50 characters of a commit subject.
 func (c *Commit) ShortMessage() string {
 	subject := strings.Split(c.Message, "\n")[0]
@@ -144,7 +172,7 @@ type Tag struct {
 	Name string
 	// Author is the original author of the tag.
 	Author Signature
-	// Signature is the PGP signature of the tag.
+	// Signature is the PGP, SSH or X.509 signature of the tag.
 	Signature string
 	// Encoded is the encoded tag, without any signature.
 	Encoded []byte
@@ -163,6 +191,28 @@ func (t *Tag) Verify(keyRings ...string) (string, error) {
 	return fingerprint, nil
 }
 
+// VerifySSH verifies the SSH Signature of the tag with the given allowed
+// signers. It returns the SHA256 fingerprint of the key the signature was
+// verified with, or an error.
+func (t *Tag) VerifySSH(allowedSigners ...string) (string, error) {
+	result, err := verifySSHSignature(t.Signature, t.Encoded, allowedSigners...)
+	if err != nil {
+		return "", fmt.Errorf("unable to verify Git tag: %w", err)
+	}
+	return result.Fingerprint, nil
+}
+
+// VerifyX509 verifies the X.509 (S/MIME) Signature of the tag with the
+// given options. It returns the subject of the certificate the signature was
+// verified with, or an error.
+func (t *Tag) VerifyX509(opts X509VerifyOptions) (string, error) {
+	result, err := verifyX509Signature(t.Signature, t.Encoded, opts)
+	if err != nil {
+		return "", fmt.Errorf("unable to verify Git tag: %w", err)
+	}
+	return result.Identity, nil
+}
+
 // String returns a short string representation of the tag in the format
 // of <name@hash>, for eg: "1.0.0@a0c14dc8580a23f79bc654faa79c4f62b46c2c22"
 // If the tag is lightweight, it won't have a hash, so it'll simply return
@@ -174,6 +224,50 @@ func (t *Tag) String() string {
 	return fmt.Sprintf("%s@%s", t.Name, t.Hash.String())
 }
 
+// ChangeAction is the action applied to a file between two revisions.
+type ChangeAction string
+
+const (
+	// ChangeActionAdd indicates the file was added.
+	ChangeActionAdd ChangeAction = "add"
+	// ChangeActionModify indicates the file was modified.
+	ChangeActionModify ChangeAction = "modify"
+	// ChangeActionDelete indicates the file was deleted.
+	ChangeActionDelete ChangeAction = "delete"
+)
+
+// FileChange describes a file that changed between two revisions.
+type FileChange struct {
+	// Path is the path of the file, relative to the root of the repository.
+	Path string
+	// Action is the action applied to the file.
+	Action ChangeAction
+}
+
+// RemoteRef is a reference advertised by a remote repository.
+type RemoteRef struct {
+	// Name is the full name of the reference, for example
+	// "refs/heads/main" or "refs/tags/v1.0.0".
+	Name string
+	// Hash is the hash of the object the reference points to. For
+	// annotated tags, this is the hash of the tag object.
+	Hash Hash
+	// PeeledHash is the hash of the commit an annotated tag points to. It
+	// is empty for other references.
+	PeeledHash Hash
+	// Target is the name of the reference a symbolic reference, such as
+	// HEAD, points to. It is empty for other references.
+	Target string
+}
+
+// CommitHash returns the hash of the commit the reference points to.
+func (r RemoteRef) CommitHash() Hash {
+	if len(r.PeeledHash) > 0 {
+		return r.PeeledHash
+	}
+	return r.Hash
+}
+
 // ErrRepositoryNotFound indicates that the repository (or the ref in
 // question) does not exist at the given URL.
 type ErrRepositoryNotFound struct {
@@ -185,9 +279,110 @@ func (e ErrRepositoryNotFound) Error() string {
 	return fmt.Sprintf("%s: git repository: '%s'", e.Message, e.URL)
 }
 
+// ErrRebaseConflict indicates that a local commit could not be re-applied on
+// top of the remote branch, because the remote changed the same paths.
+type ErrRebaseConflict struct {
+	// Branch is the name of the branch the commit was re-applied to.
+	Branch string
+	// Commit is the hash of the local commit that could not be re-applied.
+	Commit string
+	// Paths are the paths with conflicting changes.
+	Paths []string
+}
+
+func (e ErrRebaseConflict) Error() string {
+	return fmt.Sprintf("unable to rebase commit '%s' onto '%s': conflicting changes to %s",
+		e.Commit, e.Branch, strings.Join(e.Paths, ", "))
+}
+
+// ErrRebaseSignedCommit indicates that a signed local commit could not be
+// re-applied on top of the remote branch, because no signer was provided
+// to sign the re-applied commit.
+type ErrRebaseSignedCommit struct {
+	// Branch is the name of the branch the commit was re-applied to.
+	Branch string
+	// Commit is the hash of the signed local commit.
+	Commit string
+}
+
+func (e ErrRebaseSignedCommit) Error() string {
+	return fmt.Sprintf("unable to rebase commit '%s' onto '%s': commit is signed and no signer was provided",
+		e.Commit, e.Branch)
+}
+
+// PushRejectionReason is the reason a push was rejected by the remote.
+type PushRejectionReason string
+
+const (
+	// PushRejectedNonFastForward indicates that the remote reference has
+	// commits which are not part of the pushed history.
+	PushRejectedNonFastForward PushRejectionReason = "NonFastForward"
+	// PushRejectedProtectedBranch indicates that the remote reference is
+	// protected against the update.
+	PushRejectedProtectedBranch PushRejectionReason = "ProtectedBranch"
+	// PushRejectedHookDeclined indicates that a server-side hook, for
+	// example pre-receive or update, declined the update.
+	PushRejectedHookDeclined PushRejectionReason = "HookDeclined"
+	// PushRejectedAuthentication indicates that the credentials are
+	// missing, invalid or lack write access.
+	PushRejectedAuthentication PushRejectionReason = "AuthenticationFailed"
+	// PushRejectedRefLock indicates that the remote failed to lock the
+	// reference for the update.
+	PushRejectedRefLock PushRejectionReason = "RefLockFailed"
+)
+
+// ErrPushRejected indicates that the remote rejected a push.
+type ErrPushRejected struct {
+	// Reason is the reason the push was rejected.
+	Reason PushRejectionReason
+	// Reference is the remote reference that was rejected, if known.
+	Reference string
+	// RemoteMessages are the lines the remote wrote to its standard error,
+	// for example the output of a hook.
+	RemoteMessages []string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e ErrPushRejected) Error() string {
+	var b strings.Builder
+	b.WriteString("push ")
+	if e.Reference != "" {
+		fmt.Fprintf(&b, "to '%s' ", e.Reference)
+	}
+	fmt.Fprintf(&b, "rejected: %s", e.Err)
+	for _, m := range e.RemoteMessages {
+		fmt.Fprintf(&b, "\nremote: %s", m)
+	}
+	return b.String()
+}
+
+func (e ErrPushRejected) Unwrap() error {
+	return e.Err
+}
+
+// ErrCommitVerification indicates that the signature of a commit in a
+// range of commits could not be verified.
+type ErrCommitVerification struct {
+	// Commit is the hash of the commit that failed the verification.
+	Commit string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e ErrCommitVerification) Error() string {
+	return fmt.Sprintf("verification of commit '%s' failed: %s", e.Commit, e.Err)
+}
+
+func (e ErrCommitVerification) Unwrap() error {
+	return e.Err
+}
+
 var (
-	ErrNoGitRepository = errors.New("no git repository")
-	ErrNoStagedFiles   = errors.New("no staged files")
+	ErrNoGitRepository     = errors.New("no git repository")
+	ErrNoStagedFiles       = errors.New("no staged files")
+	ErrNoteNotFound        = errors.New("note not found")
+	ErrVerifyDepthExceeded = errors.New("maximum verification depth exceeded")
 )
 
 // IsConcreteCommit returns if a given commit is a concrete commit. Concrete
@@ -211,20 +406,9 @@ func IsSignedTag(t Tag) bool {
 }
 
 func verifySignature(sig string, payload []byte, keyRings ...string) (string, error) {
-	if sig == "" {
-		return "", fmt.Errorf("unable to verify payload as the provided signature is empty")
-	}
-
-	for _, r := range keyRings {
-		reader := strings.NewReader(r)
-		keyring, err := openpgp.ReadArmoredKeyRing(reader)
-		if err != nil {
-			return "", fmt.Errorf("unable to read armored key ring: %w", err)
-		}
-		signer, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewBuffer(payload), bytes.NewBufferString(sig), nil)
-		if err == nil {
-			return signer.PrimaryKey.KeyIdString(), nil
-		}
-	}
-	return "", fmt.Errorf("unable to verify payload with any of the given key rings")
+	result, err := verifyOpenPGPSignature(sig, payload, keyRings...)
+	if err != nil {
+		return "", err
+	}
+	return result.KeyID, nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package git
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -133,7 +134,7 @@ func TestHash_Algorithm(t *testing.T) {
 		{
 			name: "SHA-256",
 			hash: Hash("6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8"),
-			want: HashTypeUnknown,
+			want: HashTypeSHA256,
 		},
 		{
 			name: "MD5",
@@ -227,9 +228,9 @@ func TestHash_Digest(t *testing.T) {
 			want: "<unknown>:dba535cd50b291777a055338572e4a4b",
 		},
 		{
-			name: "With an unknown (SHA-256) hash",
+			name: "With a SHA-256 hash",
 			hash: Hash("6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8"),
-			want: "<unknown>:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+			want: "sha256:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
 		},
 		{
 			name: "With a nil hash",
@@ -403,3 +404,40 @@ func TestIsConcreteCommit(t *testing.T) {
 		})
 	}
 }
+
+func TestErrPushRejected_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  ErrPushRejected
+		want string
+	}{
+		{
+			name: "with reference and remote messages",
+			err: ErrPushRejected{
+				Reason:         PushRejectedHookDeclined,
+				Reference:      "refs/heads/main",
+				RemoteMessages: []string{"policy check failed", "error: hook declined to update refs/heads/main"},
+				Err:            errors.New("command error on refs/heads/main: hook declined"),
+			},
+			want: "push to 'refs/heads/main' rejected: command error on refs/heads/main: hook declined\n" +
+				"remote: policy check failed\n" +
+				"remote: error: hook declined to update refs/heads/main",
+		},
+		{
+			name: "without reference",
+			err: ErrPushRejected{
+				Reason: PushRejectedAuthentication,
+				Err:    errors.New("authentication required"),
+			},
+			want: "push rejected: authentication required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(tt.err.Error()).To(Equal(tt.want))
+			g.Expect(errors.Unwrap(tt.err)).To(Equal(tt.err.Err))
+		})
+	}
+}
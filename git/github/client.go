@@ -0,0 +1,344 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package github provides a client to mint GitHub App installation access
+// tokens, which can be used to authenticate Git operations over HTTPS.
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AppIDKey is the key of the GitHub App ID in the secret data.
+	AppIDKey = "githubAppID"
+	// AppInstallationIDKey is the key of the GitHub App installation ID in
+	// the secret data.
+	AppInstallationIDKey = "githubAppInstallationID"
+	// AppPrivateKey is the key of the PEM encoded private key of the GitHub
+	// App in the secret data.
+	AppPrivateKey = "githubAppPrivateKey"
+	// AppBaseURLKey is the key of the GitHub API base URL in the secret
+	// data, for example "https://github.example.com/api/v3" for GitHub
+	// Enterprise Server.
+	AppBaseURLKey = "githubAppBaseURL"
+
+	// DefaultAppBaseURL is the base URL of the GitHub API.
+	DefaultAppBaseURL = "https://api.github.com"
+	// AccessTokenUsername is the username to use in combination with an
+	// installation access token for Git operations over HTTPS.
+	AccessTokenUsername = "x-access-token"
+)
+
+const (
+	// jwtExpiry is the expiry of the JSON Web Token used to request an
+	// installation access token. GitHub rejects tokens which expire more
+	// than ten minutes in the future.
+	jwtExpiry = 9 * time.Minute
+	// jwtClockDrift is subtracted from the issue time of the JSON Web
+	// Token, to allow for clock drift with GitHub.
+	jwtClockDrift = time.Minute
+	// tokenExpiryMargin is the time before its expiry a cached installation
+	// access token is renewed, so that it does not expire during a Git
+	// operation.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+// Client mints installation access tokens for a GitHub App.
+type Client struct {
+	appID          string
+	installationID string
+	privateKey     []byte
+	apiURL         string
+	httpClient     *http.Client
+}
+
+// OptFunc configures a Client.
+type OptFunc func(*Client)
+
+// AppToken is an installation access token of a GitHub App.
+type AppToken struct {
+	// Token is the installation access token.
+	Token string `json:"token"`
+	// ExpiresAt is the time the token expires.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// New returns a new Client configured with the given options. The App ID,
+// installation ID and private key are required.
+func New(opts ...OptFunc) (*Client, error) {
+	c := &Client{
+		apiURL:     DefaultAppBaseURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.appID == "" {
+		return nil, errors.New("github app id must be provided")
+	}
+	if c.installationID == "" {
+		return nil, errors.New("github app installation id must be provided")
+	}
+	if len(c.privateKey) == 0 {
+		return nil, errors.New("github app private key must be provided")
+	}
+	return c, nil
+}
+
+// WithAppID configures the ID of the GitHub App.
+func WithAppID(appID string) OptFunc {
+	return func(c *Client) {
+		c.appID = appID
+	}
+}
+
+// WithInstallationID configures the ID of the installation of the GitHub
+// App to mint tokens for.
+func WithInstallationID(installationID string) OptFunc {
+	return func(c *Client) {
+		c.installationID = installationID
+	}
+}
+
+// WithPrivateKey configures the PEM encoded private key of the GitHub App.
+func WithPrivateKey(privateKey []byte) OptFunc {
+	return func(c *Client) {
+		c.privateKey = privateKey
+	}
+}
+
+// WithAppBaseURL configures the base URL of the GitHub API. Defaults to
+// DefaultAppBaseURL.
+func WithAppBaseURL(appBaseURL string) OptFunc {
+	return func(c *Client) {
+		c.apiURL = strings.TrimSuffix(appBaseURL, "/")
+	}
+}
+
+// WithHTTPClient configures the HTTP client used to request tokens.
+func WithHTTPClient(httpClient *http.Client) OptFunc {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAppData configures the client using the App ID, installation ID,
+// private key and optional base URL in the given secret data.
+func WithAppData(data map[string][]byte) OptFunc {
+	return func(c *Client) {
+		c.appID = string(data[AppIDKey])
+		c.installationID = string(data[AppInstallationIDKey])
+		c.privateKey = data[AppPrivateKey]
+		if baseURL := string(data[AppBaseURLKey]); baseURL != "" {
+			c.apiURL = strings.TrimSuffix(baseURL, "/")
+		}
+	}
+}
+
+// tokenCache holds the installation access tokens minted by any Client,
+// keyed by Client.cacheKey. The mutex only guards the map, while each entry
+// has its own mutex, so that a token request does not block the clients
+// with a different configuration.
+var tokenCache = struct {
+	sync.Mutex
+	entries map[string]*tokenCacheEntry
+}{entries: make(map[string]*tokenCacheEntry)}
+
+// tokenCacheEntry holds the cached token of a client configuration. Its
+// mutex is held while a token is requested, so that concurrent callers
+// wait for and share the same token.
+type tokenCacheEntry struct {
+	sync.Mutex
+	token *AppToken
+}
+
+// cacheEntry returns the entry of the token cache for the client,
+// adding it if it does not exist. Entries of other configurations without
+// a valid token are evicted, so that the cache does not grow with every
+// configuration seen by the process.
+func (c *Client) cacheEntry() *tokenCacheEntry {
+	key := c.cacheKey()
+
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	now := time.Now()
+	for k, e := range tokenCache.entries {
+		// An entry that is locked has a token request in flight.
+		if k == key || !e.TryLock() {
+			continue
+		}
+		if e.token == nil || !now.Before(e.token.ExpiresAt) {
+			delete(tokenCache.entries, k)
+		}
+		e.Unlock()
+	}
+	e, ok := tokenCache.entries[key]
+	if !ok {
+		e = &tokenCacheEntry{}
+		tokenCache.entries[key] = e
+	}
+	return e
+}
+
+// GetToken returns an installation access token for the GitHub App
+// installation. Tokens are cached in memory and shared by clients with the
+// same configuration, until shortly before they expire.
+func (c *Client) GetToken(ctx context.Context) (*AppToken, error) {
+	e := c.cacheEntry()
+
+	e.Lock()
+	defer e.Unlock()
+	if e.token != nil && time.Until(e.token.ExpiresAt) > tokenExpiryMargin {
+		return e.token, nil
+	}
+
+	t, err := c.requestToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.token = t
+	return t, nil
+}
+
+// InvalidateToken removes the cached installation access token of the
+// client, so that the next call to GetToken mints a new token.
+func (c *Client) InvalidateToken() {
+	e := c.cacheEntry()
+
+	e.Lock()
+	defer e.Unlock()
+	e.token = nil
+}
+
+// requestToken requests a new installation access token from the GitHub
+// API.
+func (c *Client) requestToken(ctx context.Context) (*AppToken, error) {
+	jwt, err := c.appJWT(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/app/installations/%s/access_tokens", c.apiURL, c.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request github app installation token: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		var resp struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&resp)
+		return nil, fmt.Errorf("unable to request github app installation token: unexpected status code %d: %s",
+			res.StatusCode, resp.Message)
+	}
+
+	var t AppToken
+	if err = json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return nil, fmt.Errorf("unable to decode github app installation token: %w", err)
+	}
+	if t.Token == "" {
+		return nil, errors.New("github app installation token response does not contain a token")
+	}
+	return &t, nil
+}
+
+// appJWT returns a JSON Web Token signed with the private key of the App,
+// to authenticate as the App at the given time.
+func (c *Client) appJWT(now time.Time) (string, error) {
+	key, err := parsePrivateKey(c.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	iat := now.Add(-jwtClockDrift)
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": iat.Unix(),
+		"exp": iat.Add(jwtExpiry).Unix(),
+		"iss": c.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign github app token request: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// cacheKey returns the key of the tokens of the client in the token cache.
+func (c *Client) cacheKey() string {
+	h := sha256.New()
+	for _, v := range []string{c.apiURL, c.appID, c.installationID, string(c.privateKey)} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parsePrivateKey parses a PEM encoded RSA private key, in either PKCS #1
+// (as generated by GitHub) or PKCS #8 format.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("unable to decode github app private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse github app private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
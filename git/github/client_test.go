@@ -0,0 +1,360 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNew(t *testing.T) {
+	key := generateKey(t)
+
+	tests := []struct {
+		name    string
+		opts    []OptFunc
+		wantErr string
+	}{
+		{
+			name: "with options",
+			opts: []OptFunc{WithAppID("123"), WithInstallationID("456"), WithPrivateKey(key)},
+		},
+		{
+			name: "with app data",
+			opts: []OptFunc{WithAppData(map[string][]byte{
+				AppIDKey:             []byte("123"),
+				AppInstallationIDKey: []byte("456"),
+				AppPrivateKey:        key,
+			})},
+		},
+		{
+			name:    "without app id",
+			opts:    []OptFunc{WithInstallationID("456"), WithPrivateKey(key)},
+			wantErr: "github app id must be provided",
+		},
+		{
+			name:    "without installation id",
+			opts:    []OptFunc{WithAppID("123"), WithPrivateKey(key)},
+			wantErr: "github app installation id must be provided",
+		},
+		{
+			name:    "without private key",
+			opts:    []OptFunc{WithAppID("123"), WithInstallationID("456")},
+			wantErr: "github app private key must be provided",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c, err := New(tt.opts...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(c.appID).To(Equal("123"))
+			g.Expect(c.installationID).To(Equal("456"))
+			g.Expect(c.apiURL).To(Equal(DefaultAppBaseURL))
+		})
+	}
+}
+
+func TestClient_GetToken(t *testing.T) {
+	g := NewWithT(t)
+
+	key := generateKey(t)
+	block, _ := pem.Decode(key)
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var requests atomic.Int32
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		installation := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/access_tokens")
+		if err := verifyJWT(r.Header.Get("Authorization"), &privateKey.PublicKey, "123"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprintf(w, `{"message": %q}`, err.Error())
+			return
+		}
+		if installation == "expiring" {
+			_, _ = w.Write([]byte(`{"message": "wrong status"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(AppToken{
+			Token:     "token-" + installation,
+			ExpiresAt: expiresAt,
+		})
+	}))
+	defer server.Close()
+
+	c, err := New(WithAppID("123"), WithInstallationID("456"), WithPrivateKey(key), WithAppBaseURL(server.URL+"/"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	token, err := c.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("token-456"))
+	g.Expect(token.ExpiresAt.Equal(expiresAt)).To(BeTrue())
+	g.Expect(requests.Load()).To(Equal(int32(1)))
+
+	// A client with the same configuration uses the cached token.
+	c, err = New(WithAppData(map[string][]byte{
+		AppIDKey:             []byte("123"),
+		AppInstallationIDKey: []byte("456"),
+		AppPrivateKey:        key,
+		AppBaseURLKey:        []byte(server.URL),
+	}))
+	g.Expect(err).ToNot(HaveOccurred())
+	token, err = c.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(token.Token).To(Equal("token-456"))
+	g.Expect(requests.Load()).To(Equal(int32(1)))
+
+	// A token close to its expiry is renewed.
+	expiresAt = time.Now().Add(tokenExpiryMargin - time.Second).UTC()
+	c, err = New(WithAppID("123"), WithInstallationID("789"), WithPrivateKey(key), WithAppBaseURL(server.URL))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(requests.Load()).To(Equal(int32(3)))
+
+	// Errors of the API are returned.
+	c, err = New(WithAppID("321"), WithInstallationID("456"), WithPrivateKey(key), WithAppBaseURL(server.URL))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.GetToken(context.TODO())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unexpected status code 401: unexpected issuer '321'"))
+
+	c, err = New(WithAppID("123"), WithInstallationID("expiring"), WithPrivateKey(key), WithAppBaseURL(server.URL))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.GetToken(context.TODO())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unexpected status code 200"))
+
+	c, err = New(WithAppID("123"), WithInstallationID("456"), WithPrivateKey([]byte("invalid")), WithAppBaseURL(server.URL))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = c.GetToken(context.TODO())
+	g.Expect(err).To(MatchError("unable to decode github app private key: no PEM data found"))
+}
+
+func TestClient_GetToken_concurrent(t *testing.T) {
+	g := NewWithT(t)
+
+	key := generateKey(t)
+
+	// The request of the "slow" installation blocks until the token of the
+	// "fast" installation has been returned.
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		installation := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/access_tokens")
+		if installation == "slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(AppToken{
+			Token:     "token-" + installation,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	newClient := func(installationID string) *Client {
+		c, err := New(WithAppID("concurrent"), WithInstallationID(installationID), WithPrivateKey(key), WithAppBaseURL(server.URL))
+		g.Expect(err).ToNot(HaveOccurred())
+		return c
+	}
+
+	slow := make(chan *AppToken, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			token, err := newClient("slow").GetToken(context.TODO())
+			if err != nil {
+				token = nil
+			}
+			slow <- token
+		}()
+	}
+	g.Eventually(requests.Load).Should(Equal(int32(1)))
+
+	fast := make(chan *AppToken, 1)
+	go func() {
+		token, err := newClient("fast").GetToken(context.TODO())
+		if err != nil {
+			token = nil
+		}
+		fast <- token
+	}()
+	var token *AppToken
+	g.Eventually(fast, 5*time.Second).Should(Receive(&token))
+	g.Expect(token).ToNot(BeNil())
+	g.Expect(token.Token).To(Equal("token-fast"))
+
+	// Concurrent callers with the same configuration share the token.
+	release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		g.Eventually(slow).Should(Receive(&token))
+		g.Expect(token).ToNot(BeNil())
+		g.Expect(token.Token).To(Equal("token-slow"))
+	}
+	g.Expect(requests.Load()).To(Equal(int32(2)))
+}
+
+func TestClient_GetToken_evictsExpired(t *testing.T) {
+	g := NewWithT(t)
+
+	key := generateKey(t)
+	expiresAt := time.Now().Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(AppToken{
+			Token:     "token",
+			ExpiresAt: expiresAt,
+		})
+	}))
+	defer server.Close()
+
+	newClient := func(installationID string) *Client {
+		c, err := New(WithAppID("evict"), WithInstallationID(installationID), WithPrivateKey(key), WithAppBaseURL(server.URL))
+		g.Expect(err).ToNot(HaveOccurred())
+		return c
+	}
+	cached := func(c *Client) bool {
+		tokenCache.Lock()
+		defer tokenCache.Unlock()
+		_, ok := tokenCache.entries[c.cacheKey()]
+		return ok
+	}
+
+	valid := newClient("valid")
+	_, err := valid.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	expiresAt = time.Now().Add(-time.Minute)
+	expired := newClient("expired")
+	_, err = expired.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	expiresAt = time.Now().Add(time.Hour)
+	invalidated := newClient("invalidated")
+	_, err = invalidated.GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	invalidated.InvalidateToken()
+	g.Expect(cached(expired)).To(BeFalse())
+
+	_, err = newClient("other").GetToken(context.TODO())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached(valid)).To(BeTrue())
+	g.Expect(cached(invalidated)).To(BeFalse())
+}
+
+func Test_parsePrivateKey(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	got, err := parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Equal(key)).To(BeTrue())
+
+	got, err = parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Equal(key)).To(BeTrue())
+
+	_, err = parsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")}))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func generateKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// verifyJWT verifies the RS256 signature and claims of the JSON Web Token in
+// the given Authorization header.
+func verifyJWT(header string, key *rsa.PublicKey, issuer string) error {
+	jwt, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return fmt.Errorf("missing bearer token")
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+	if claims.Issuer != issuer {
+		return fmt.Errorf("unexpected issuer '%s'", claims.Issuer)
+	}
+	now := time.Now().Unix()
+	if claims.IssuedAt > now || claims.ExpiresAt < now || claims.ExpiresAt-claims.IssuedAt > 600 {
+		return fmt.Errorf("invalid token lifetime")
+	}
+	return nil
+}
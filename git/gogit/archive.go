@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+	"github.com/fluxcd/pkg/sourceignore"
+)
+
+// archiveModTime is the modification time of all archive entries.
+var archiveModTime = time.Unix(0, 0)
+
+// Archive writes a gzip compressed tarball of the tree of the commit the
+// given revision resolves to, to w. The tree is read from the object
+// storage, which allows archiving clones without a worktree, for example
+// those made with WithMemoryStorage.
+//
+// The archive is deterministic: entries are written in tree order, with a
+// fixed modification time and owner, and permissions derived from the Git
+// file mode. Symbolic links are archived as links, while submodules are
+// omitted.
+func (g *Client) Archive(ctx context.Context, rev string, w io.Writer, opts ...repository.ArchiveOption) error {
+	if g.repository == nil {
+		return git.ErrNoGitRepository
+	}
+
+	o := &repository.ArchiveOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	commit, err := resolveCommit(g.repository, rev)
+	if err != nil {
+		return fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("unable to get tree of revision '%s': %w", rev, err)
+	}
+	if dir := strings.Trim(path.Clean(filepath.ToSlash(o.Path)), "/"); dir != "." && dir != "" {
+		if tree, err = tree.Tree(dir); err != nil {
+			return fmt.Errorf("unable to find directory '%s' in revision '%s': %w", dir, rev, err)
+		}
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	var patterns []gitignore.Pattern
+	if o.DefaultIgnore {
+		patterns = append(sourceignore.VCSPatterns(nil), sourceignore.DefaultPatterns(nil)...)
+	}
+	patterns = append(patterns, sourceignore.ReadPatterns(strings.NewReader(strings.Join(o.Ignore, "\n")), nil)...)
+	if err = archiveTree(ctx, tw, tree, nil, patterns, o.IgnoreFile); err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// archiveTree writes the entries of the tree in the directory with the
+// given path segments to tw, excluding those matching the patterns or the
+// patterns of the ignore files of the tree and its subtrees.
+func archiveTree(ctx context.Context, tw *tar.Writer, tree *object.Tree, dir []string,
+	patterns []gitignore.Pattern, ignoreFile string) error {
+	if ignoreFile != "" {
+		f, err := tree.File(ignoreFile)
+		if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+			return fmt.Errorf("unable to read '%s': %w", path.Join(path.Join(dir...), ignoreFile), err)
+		}
+		if f != nil {
+			contents, err := f.Contents()
+			if err != nil {
+				return fmt.Errorf("unable to read '%s': %w", path.Join(path.Join(dir...), ignoreFile), err)
+			}
+			patterns = append(patterns[:len(patterns):len(patterns)], sourceignore.ReadPatterns(strings.NewReader(contents), dir)...)
+		}
+	}
+	matcher := gitignore.NewMatcher(patterns)
+
+	for _, e := range tree.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p := append(dir[:len(dir):len(dir)], e.Name)
+		if e.Mode == filemode.Submodule || matcher.Match(p, e.Mode == filemode.Dir) {
+			continue
+		}
+		name := path.Join(p...)
+		hdr := &tar.Header{
+			Name:    name,
+			ModTime: archiveModTime,
+		}
+
+		switch e.Mode {
+		case filemode.Dir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.Mode = 0o755
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			sub, err := tree.Tree(e.Name)
+			if err != nil {
+				return fmt.Errorf("unable to read directory '%s': %w", name, err)
+			}
+			if err = archiveTree(ctx, tw, sub, p, patterns, ignoreFile); err != nil {
+				return err
+			}
+		case filemode.Symlink:
+			f, err := tree.TreeEntryFile(&e)
+			if err != nil {
+				return fmt.Errorf("unable to read symlink '%s': %w", name, err)
+			}
+			target, err := f.Contents()
+			if err != nil {
+				return fmt.Errorf("unable to read symlink '%s': %w", name, err)
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+			hdr.Mode = 0o777
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+		default:
+			f, err := tree.TreeEntryFile(&e)
+			if err != nil {
+				return fmt.Errorf("unable to read file '%s': %w", name, err)
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = f.Size
+			hdr.Mode = 0o644
+			if e.Mode == filemode.Executable {
+				hdr.Mode = 0o755
+			}
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if err = copyBlob(tw, f); err != nil {
+				return fmt.Errorf("unable to read file '%s': %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyBlob copies the contents of the file to w.
+func copyBlob(w io.Writer, f *object.File) error {
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
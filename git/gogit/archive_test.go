@@ -0,0 +1,244 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestClient_Archive(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for f, content := range map[string]string{
+		"README.md":                    "readme",
+		".gitignore":                   "/tmp/\n",
+		".github/ci.yaml":              "ci",
+		".sourceignore":                "# Ignore docs\ndocs/\n",
+		"docs/index.md":                "docs",
+		"deploy/app.yaml":              "app",
+		"deploy/secret.enc":            "secret",
+		"deploy/.sourceignore":         "*.enc\n",
+		"deploy/nested/keep.enc":       "keep",
+		"deploy/nested/.sourceignore":  "!keep.enc\n",
+		"deploy/nested/docs/readme.md": "nested docs",
+	} {
+		_, err = commitFile(repo, f, content, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	// Add an executable, a symlink and a submodule to the tree.
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	parent, err := repo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := parent.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	readme, err := base.FindEntry("README.md")
+	g.Expect(err).ToNot(HaveOccurred())
+	target := repo.Storer.NewEncodedObject()
+	target.SetType(plumbing.BlobObject)
+	w, err := target.Writer()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = w.Write([]byte("README.md"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(w.Close()).To(Succeed())
+	targetHash, err := repo.Storer.SetEncodedObject(target)
+	g.Expect(err).ToNot(HaveOccurred())
+	treeHash, err := writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"bin/run.sh": {Mode: filemode.Executable, Hash: readme.Hash},
+		"link":       {Mode: filemode.Symlink, Hash: targetHash},
+		"submodule":  {Mode: filemode.Submodule, Hash: head.Hash()},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit := &object.Commit{
+		Author:       *mockSignature(time.Now()),
+		Committer:    *mockSignature(time.Now()),
+		Message:      "Add executable, symlink and submodule",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash()},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	g.Expect(commit.Encode(obj)).To(Succeed())
+	commitHash, err := repo.Storer.SetEncodedObject(obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), commitHash))).To(Succeed())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ggc.Archive(context.TODO(), "HEAD", io.Discard)).To(Equal(git.ErrNoGitRepository))
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tests := []struct {
+		name    string
+		rev     string
+		opts    []repository.ArchiveOption
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "archives the tree of the revision",
+			rev:  "HEAD",
+			want: map[string]string{
+				".github/":                     "0755 ",
+				".github/ci.yaml":              "0644 ci",
+				".gitignore":                   "0644 /tmp/\n",
+				".sourceignore":                "0644 # Ignore docs\ndocs/\n",
+				"README.md":                    "0644 readme",
+				"bin/":                         "0755 ",
+				"bin/run.sh":                   "0755 readme",
+				"deploy/":                      "0755 ",
+				"deploy/.sourceignore":         "0644 *.enc\n",
+				"deploy/app.yaml":              "0644 app",
+				"deploy/nested/":               "0755 ",
+				"deploy/nested/.sourceignore":  "0644 !keep.enc\n",
+				"deploy/nested/docs/":          "0755 ",
+				"deploy/nested/docs/readme.md": "0644 nested docs",
+				"deploy/nested/keep.enc":       "0644 keep",
+				"deploy/secret.enc":            "0644 secret",
+				"docs/":                        "0755 ",
+				"docs/index.md":                "0644 docs",
+				"link":                         "0777 -> README.md",
+			},
+		},
+		{
+			name: "excludes paths matching the ignore patterns and files",
+			rev:  commitHash.String(),
+			opts: []repository.ArchiveOption{
+				repository.WithArchiveIgnore("/bin/", "link", "*.md"),
+				repository.WithArchiveIgnoreFile(".sourceignore"),
+			},
+			want: map[string]string{
+				".github/":                    "0755 ",
+				".github/ci.yaml":             "0644 ci",
+				".gitignore":                  "0644 /tmp/\n",
+				".sourceignore":               "0644 # Ignore docs\ndocs/\n",
+				"deploy/":                     "0755 ",
+				"deploy/.sourceignore":        "0644 *.enc\n",
+				"deploy/app.yaml":             "0644 app",
+				"deploy/nested/":              "0755 ",
+				"deploy/nested/.sourceignore": "0644 !keep.enc\n",
+				"deploy/nested/keep.enc":      "0644 keep",
+			},
+		},
+		{
+			name: "excludes paths matching the default patterns",
+			rev:  "HEAD",
+			opts: []repository.ArchiveOption{
+				repository.WithArchiveDefaultIgnore(),
+				repository.WithArchiveIgnore("/bin/", "/deploy/", "/docs/", "!/.gitignore"),
+			},
+			want: map[string]string{
+				".gitignore":    "0644 /tmp/\n",
+				".sourceignore": "0644 # Ignore docs\ndocs/\n",
+				"README.md":     "0644 readme",
+				"link":          "0777 -> README.md",
+			},
+		},
+		{
+			name: "archives the contents of the directory",
+			rev:  "HEAD",
+			opts: []repository.ArchiveOption{
+				repository.WithArchivePath("./deploy/nested/"),
+				repository.WithArchiveIgnore("/.sourceignore"),
+			},
+			want: map[string]string{
+				"docs/":          "0755 ",
+				"docs/readme.md": "0644 nested docs",
+				"keep.enc":       "0644 keep",
+			},
+		},
+		{
+			name:    "errors on unknown revision",
+			rev:     "invalid",
+			wantErr: "unable to resolve revision 'invalid'",
+		},
+		{
+			name:    "errors on unknown directory",
+			rev:     "HEAD",
+			opts:    []repository.ArchiveOption{repository.WithArchivePath("missing")},
+			wantErr: "unable to find directory 'missing' in revision 'HEAD'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			var buf bytes.Buffer
+			err := ggc.Archive(context.TODO(), tt.rev, &buf, tt.opts...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(readArchive(t, buf.Bytes())).To(Equal(tt.want))
+
+			// The archive of the same revision is identical.
+			var again bytes.Buffer
+			g.Expect(ggc.Archive(context.TODO(), tt.rev, &again, tt.opts...)).To(Succeed())
+			g.Expect(again.Bytes()).To(Equal(buf.Bytes()))
+		})
+	}
+}
+
+// readArchive returns the entries of the gzip compressed tarball, mapped to
+// their permissions and contents or link target.
+func readArchive(t *testing.T, data []byte) map[string]string {
+	g := NewWithT(t)
+
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	g.Expect(err).ToNot(HaveOccurred())
+	tr := tar.NewReader(gr)
+	entries := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(hdr.ModTime.Unix()).To(BeZero())
+		var content string
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			content = "-> " + hdr.Linkname
+		default:
+			b, err := io.ReadAll(tr)
+			g.Expect(err).ToNot(HaveOccurred())
+			content = string(b)
+		}
+		entries[hdr.Name] = fmt.Sprintf("%04o %s", hdr.Mode, content)
+	}
+	return entries
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// CommitBuilder builds a commit on top of HEAD entirely in the object
+// storage of the repository, without reading or writing the worktree. This
+// avoids a checkout of the repository, which makes it suitable for clones
+// of large repositories made with WithMemoryStorage, or with NoCheckout.
+//
+// The worktree and index are not updated by a commit, and therefore no
+// longer match HEAD afterwards.
+type CommitBuilder struct {
+	client *Client
+	base   *object.Tree
+	parent *object.Commit
+	head   plumbing.ReferenceName
+	edits  map[string]*object.TreeEntry
+}
+
+// NewCommitBuilder returns a CommitBuilder for a commit on top of the
+// current HEAD. If HEAD points to a branch without commits, the commit
+// becomes the first commit of the branch.
+func (g *Client) NewCommitBuilder() (*CommitBuilder, error) {
+	if g.repository == nil {
+		return nil, git.ErrNoGitRepository
+	}
+
+	b := &CommitBuilder{client: g}
+	if err := b.reset(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// reset sets the base of the builder to the current HEAD, and discards
+// any edits.
+func (b *CommitBuilder) reset() error {
+	repo := b.client.repository
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return fmt.Errorf("unable to resolve HEAD: %w", err)
+	}
+	b.head, b.base, b.parent = plumbing.HEAD, nil, nil
+	if head.Type() == plumbing.SymbolicReference {
+		b.head = head.Target()
+		if head, err = repo.Reference(b.head, true); err != nil {
+			if errors.Is(err, plumbing.ErrReferenceNotFound) {
+				b.edits = make(map[string]*object.TreeEntry)
+				return nil
+			}
+			return fmt.Errorf("unable to resolve HEAD: %w", err)
+		}
+	}
+	if b.parent, err = repo.CommitObject(head.Hash()); err != nil {
+		return fmt.Errorf("unable to resolve commit of HEAD: %w", err)
+	}
+	if b.base, err = b.parent.Tree(); err != nil {
+		return fmt.Errorf("unable to get tree of HEAD: %w", err)
+	}
+	b.edits = make(map[string]*object.TreeEntry)
+	return nil
+}
+
+// WriteFile writes the content to the object storage, and adds or
+// replaces the file at the given path with it in the commit. The mode of
+// an existing executable file is retained. It returns an error if the path
+// is a directory, or if a parent of the path is a file, which must be
+// deleted first to replace it.
+func (b *CommitBuilder) WriteFile(path string, content io.Reader) error {
+	p, err := builderPath(path)
+	if err != nil {
+		return err
+	}
+	if err = b.checkParents(p); err != nil {
+		return fmt.Errorf("unable to write '%s': %w", p, err)
+	}
+	isDir, err := b.isDir(p)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		return fmt.Errorf("unable to write '%s': is a directory", p)
+	}
+
+	s := b.client.repository.Storer
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, content); err != nil {
+		w.Close()
+		return fmt.Errorf("unable to write '%s': %w", p, err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("unable to write '%s': %w", p, err)
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return fmt.Errorf("unable to write '%s': %w", p, err)
+	}
+
+	mode := filemode.Regular
+	if e, ok := b.edits[p]; ok {
+		if e != nil && e.Mode == filemode.Executable {
+			mode = filemode.Executable
+		}
+	} else if !b.deletedParent(p) {
+		e, err := findTreeEntry(b.base, p)
+		if err != nil {
+			return err
+		}
+		if e != nil && e.Mode == filemode.Executable {
+			mode = filemode.Executable
+		}
+	}
+	b.edits[p] = &object.TreeEntry{Mode: mode, Hash: hash}
+	return nil
+}
+
+// isDir returns true if the path is a directory in the commit, either in
+// HEAD or because files have been written to it.
+func (b *CommitBuilder) isDir(p string) (bool, error) {
+	for name, e := range b.edits {
+		if e != nil && strings.HasPrefix(name, p+"/") {
+			return true, nil
+		}
+	}
+	if _, ok := b.edits[p]; ok || b.deletedParent(p) {
+		return false, nil
+	}
+	e, err := findTreeEntry(b.base, p)
+	if err != nil {
+		return false, err
+	}
+	return e != nil && e.Mode == filemode.Dir, nil
+}
+
+// checkParents returns a notDirectoryError if a parent of the path is a
+// file in the commit, either in HEAD and not deleted, or because it has
+// been written.
+func (b *CommitBuilder) checkParents(p string) error {
+	segments := strings.Split(p, "/")
+	inBase := true
+	for i := 1; i < len(segments); i++ {
+		dir := strings.Join(segments[:i], "/")
+		if e, ok := b.edits[dir]; ok {
+			if e != nil {
+				return notDirectoryError{Path: dir}
+			}
+			// Nothing of HEAD remains below a deleted path.
+			inBase = false
+			continue
+		}
+		if !inBase {
+			continue
+		}
+		e, err := findTreeEntry(b.base, dir)
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			inBase = false
+		} else if e.Mode != filemode.Dir {
+			return notDirectoryError{Path: dir}
+		}
+	}
+	return nil
+}
+
+// Delete removes the file or directory at the given path in the commit,
+// including any files written to it before. It returns an error if the
+// path does not exist.
+func (b *CommitBuilder) Delete(path string) error {
+	p, err := builderPath(path)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for e := range b.edits {
+		if strings.HasPrefix(e, p+"/") {
+			delete(b.edits, e)
+			found = true
+		}
+	}
+	if e, ok := b.edits[p]; ok {
+		found = found || e != nil
+		delete(b.edits, p)
+	}
+	// A path below a file of HEAD does not exist.
+	e, err := findTreeEntry(b.base, p)
+	var nd notDirectoryError
+	if err != nil && !errors.As(err, &nd) {
+		return err
+	}
+	if e != nil && !b.deletedParent(p) {
+		b.edits[p] = nil
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("unable to delete '%s': %w", p, iofs.ErrNotExist)
+	}
+	return nil
+}
+
+// deletedParent returns true if a parent directory of the path has been
+// deleted.
+func (b *CommitBuilder) deletedParent(p string) bool {
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if e, ok := b.edits[dir]; ok && e == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Commit writes the tree with the changes made by the builder, and creates
+// a commit of it with the given info on top of HEAD, after which HEAD, or
+// the branch it points to, is updated to the commit. The builder can be
+// used for a next commit afterwards.
+//
+// Of the commit options, the signer and trailers are supported, and the
+// files of WithDeletedFiles and WithFiles are applied as with Delete and
+// WriteFile, in that order. Renames are not supported.
+// If the commit does not change the tree of HEAD, git.ErrNoStagedFiles is
+// returned with the hash of HEAD.
+func (b *CommitBuilder) Commit(info git.Commit, commitOpts ...repository.CommitOption) (string, error) {
+	options := &repository.CommitOptions{}
+	for _, o := range commitOpts {
+		o(options)
+	}
+	if len(options.RenamedFiles) > 0 {
+		return "", errors.New("unable to commit: renames are not supported by the commit builder")
+	}
+	for _, path := range options.DeletedFiles {
+		if err := b.Delete(path); err != nil {
+			return "", err
+		}
+	}
+	for path, content := range options.Files {
+		if err := b.WriteFile(path, content); err != nil {
+			return "", err
+		}
+	}
+
+	if b.parent == nil && len(b.edits) == 0 {
+		return "", git.ErrNoStagedFiles
+	}
+
+	repo := b.client.repository
+	treeHash, err := writeTree(repo.Storer, b.base, b.edits)
+	if err != nil {
+		return "", fmt.Errorf("unable to write tree: %w", err)
+	}
+	var parents []plumbing.Hash
+	if b.parent != nil {
+		if b.parent.TreeHash == treeHash {
+			return b.parent.Hash.String(), git.ErrNoStagedFiles
+		}
+		parents = append(parents, b.parent.Hash)
+	}
+
+	now := time.Now()
+	author := buildObjectSignature(info.Author, now)
+	// The committer defaults to the author.
+	committer := author
+	if info.Committer.Name != "" || info.Committer.Email != "" {
+		committer = buildObjectSignature(info.Committer, now)
+	}
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      appendTrailers(info.Message, options.Trailers),
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	if options.Signer != nil {
+		if commit.PGPSignature, err = signCommit(commit, options.Signer); err != nil {
+			return "", fmt.Errorf("unable to sign commit: %w", err)
+		}
+	}
+
+	obj := repo.Storer.NewEncodedObject()
+	if err = commit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	if err = repo.Storer.SetReference(plumbing.NewHashReference(b.head, hash)); err != nil {
+		return "", fmt.Errorf("unable to update '%s': %w", b.head, err)
+	}
+	if err = b.reset(); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// signCommit returns the armored detached OpenPGP signature of the commit.
+func signCommit(commit *object.Commit, signer *openpgp.Entity) (string, error) {
+	obj := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(obj); err != nil {
+		return "", err
+	}
+	r, err := obj.Reader()
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err = openpgp.ArmoredDetachSign(&b, signer, r, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// builderPath returns the cleaned slash separated form of the given path,
+// or an error if it is not relative to the root of the repository.
+func builderPath(p string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "./")
+	if !iofs.ValidPath(cleaned) || cleaned == "." {
+		return "", fmt.Errorf("invalid path '%s'", p)
+	}
+	for _, s := range strings.Split(cleaned, "/") {
+		if s == extgogit.GitDirName {
+			return "", fmt.Errorf("invalid path '%s'", p)
+		}
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	iofs "io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestCommitBuilder(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, f := range []string{"README.md", "apps/app.yaml", "apps/remove/remove.yaml", "infra/infra.yaml"} {
+		_, err = commitFile(repo, f, f, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	parent, err := repo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := parent.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	readme, err := base.FindEntry("README.md")
+	g.Expect(err).ToNot(HaveOccurred())
+	treeHash, err := writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"infra/script.sh": {Mode: filemode.Executable, Hash: readme.Hash},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit := &object.Commit{
+		Author:       *mockSignature(time.Now()),
+		Committer:    *mockSignature(time.Now()),
+		Message:      "Add script",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash()},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	g.Expect(commit.Encode(obj)).To(Succeed())
+	parentHash, err := repo.Storer.SetEncodedObject(obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), parentHash))).To(Succeed())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.NewCommitBuilder()
+	g.Expect(err).To(Equal(git.ErrNoGitRepository))
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	signer, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	var keyRing bytes.Buffer
+	w, err := armor.Encode(&keyRing, openpgp.PublicKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(signer.Serialize(w)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+
+	b, err := ggc.NewCommitBuilder()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(b.WriteFile("apps/app.yaml", strings.NewReader("updated"))).To(Succeed())
+	g.Expect(b.WriteFile("./infra/script.sh", strings.NewReader("echo"))).To(Succeed())
+	g.Expect(b.WriteFile("apps/remove/new.yaml", strings.NewReader("removed with directory"))).To(Succeed())
+	g.Expect(b.Delete("apps/remove")).To(Succeed())
+	g.Expect(b.Delete("apps/remove/remove.yaml")).To(MatchError(iofs.ErrNotExist))
+	g.Expect(b.Delete("missing")).To(MatchError(iofs.ErrNotExist))
+	g.Expect(b.WriteFile("../outside", strings.NewReader(""))).To(MatchError("invalid path '../outside'"))
+	g.Expect(b.WriteFile(".git/config", strings.NewReader(""))).To(MatchError("invalid path '.git/config'"))
+	g.Expect(b.WriteFile("apps", strings.NewReader(""))).To(MatchError("unable to write 'apps': is a directory"))
+	g.Expect(b.WriteFile("pending/file.yaml", strings.NewReader("pending"))).To(Succeed())
+	g.Expect(b.WriteFile("pending", strings.NewReader(""))).To(MatchError("unable to write 'pending': is a directory"))
+	g.Expect(b.Delete("pending")).To(Succeed())
+	// A deleted directory can be replaced with a file.
+	g.Expect(b.WriteFile("apps/remove", strings.NewReader("file"))).To(Succeed())
+	g.Expect(b.Delete("apps/remove")).To(Succeed())
+
+	hash, err := b.Commit(git.Commit{
+		Author:  git.Signature{Name: "Test User", Email: "test@example.com"},
+		Message: "Update apps",
+	},
+		repository.WithFiles(map[string]io.Reader{"new/file.yaml": strings.NewReader("new")}),
+		repository.WithDeletedFiles("infra/infra.yaml"),
+		repository.WithTrailers(repository.Trailer{Key: "Signed-off-by", Value: "Test User <test@example.com>"}),
+		repository.WithSigner(signer),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cc, err := ggc.repository.CommitObject(plumbing.NewHash(hash))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.ParentHashes).To(Equal([]plumbing.Hash{parentHash}))
+	g.Expect(strings.TrimSpace(cc.Message)).To(Equal("Update apps\n\nSigned-off-by: Test User <test@example.com>"))
+	g.Expect(cc.Committer.Name).To(Equal("Test User"))
+	_, err = cc.Verify(keyRing.String())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tree, err := cc.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	files := make(map[string]string)
+	g.Expect(tree.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		files[f.Name] = f.Mode.String() + " " + content
+		return err
+	})).To(Succeed())
+	g.Expect(files).To(Equal(map[string]string{
+		"README.md":       "0100644 README.md",
+		"apps/app.yaml":   "0100644 updated",
+		"infra/script.sh": "0100755 echo",
+		"new/file.yaml":   "0100644 new",
+	}))
+
+	// HEAD is updated, while the worktree is left as-is.
+	ref, err := ggc.repository.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref.Name()).To(Equal(plumbing.NewBranchReferenceName(git.DefaultBranch)))
+	g.Expect(ref.Hash().String()).To(Equal(hash))
+	content, err := util.ReadFile(ggc.worktreeFS, "apps/app.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(content)).To(Equal("apps/app.yaml"))
+
+	// The builder continues from the new HEAD.
+	next, err := b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Nothing"})
+	g.Expect(err).To(Equal(git.ErrNoStagedFiles))
+	g.Expect(next).To(Equal(hash))
+	g.Expect(b.WriteFile("apps/app.yaml", strings.NewReader("updated"))).To(Succeed())
+	_, err = b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Same content"})
+	g.Expect(err).To(Equal(git.ErrNoStagedFiles))
+	_, err = b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Rename"},
+		repository.WithRenamedFiles(map[string]string{"README.md": "README"}))
+	g.Expect(err).To(MatchError(ContainSubstring("renames are not supported")))
+
+	// The commit can be pushed.
+	g.Expect(ggc.Push(context.TODO(), repository.PushConfig{
+		Refspecs: []string{"refs/heads/master:refs/heads/automation"},
+	})).To(Succeed())
+	remoteRef, err := repo.Reference(plumbing.NewBranchReferenceName("automation"), false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remoteRef.Hash().String()).To(Equal(hash))
+}
+
+func TestCommitBuilder_parentIsFile(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, f := range []string{"README.md", "apps/app.yaml"} {
+		_, err = commitFile(repo, f, f, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	b, err := ggc.NewCommitBuilder()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(b.WriteFile("README.md/file", strings.NewReader(""))).To(
+		MatchError("unable to write 'README.md/file': 'README.md' is not a directory"))
+	g.Expect(b.WriteFile("apps/app.yaml/nested/file", strings.NewReader(""))).To(
+		MatchError("unable to write 'apps/app.yaml/nested/file': 'apps/app.yaml' is not a directory"))
+	g.Expect(b.Delete("README.md/file")).To(MatchError(iofs.ErrNotExist))
+	g.Expect(b.WriteFile("pending", strings.NewReader(""))).To(Succeed())
+	g.Expect(b.WriteFile("pending/file", strings.NewReader(""))).To(
+		MatchError("unable to write 'pending/file': 'pending' is not a directory"))
+
+	// A deleted file can be replaced with a directory.
+	g.Expect(b.Delete("README.md")).To(Succeed())
+	g.Expect(b.WriteFile("README.md/file", strings.NewReader("file"))).To(Succeed())
+	g.Expect(b.Delete("pending")).To(Succeed())
+	_, err = b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Replace file"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	head, err := ggc.repository.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	cc, err := ggc.repository.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err := cc.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	var files []string
+	g.Expect(tree.Files().ForEach(func(f *object.File) error {
+		files = append(files, f.Name)
+		return nil
+	})).To(Succeed())
+	g.Expect(files).To(ConsistOf("README.md/file", "apps/app.yaml"))
+}
+
+func TestCommitBuilder_emptyRepository(t *testing.T) {
+	g := NewWithT(t)
+
+	ggc, err := NewClient(t.TempDir(), nil, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ggc.Init(context.TODO(), "https://example.com/repo.git", "main")).To(Succeed())
+
+	b, err := ggc.NewCommitBuilder()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Empty"})
+	g.Expect(err).To(Equal(git.ErrNoStagedFiles))
+
+	g.Expect(b.WriteFile("README.md", strings.NewReader("readme"))).To(Succeed())
+	hash, err := b.Commit(git.Commit{Author: git.Signature{Name: "Test User"}, Message: "Initial commit"})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ref, err := ggc.repository.Reference(plumbing.NewBranchReferenceName("main"), false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref.Hash().String()).To(Equal(hash))
+	cc, err := ggc.repository.CommitObject(ref.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.ParentHashes).To(BeEmpty())
+}
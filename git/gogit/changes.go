@@ -0,0 +1,236 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+// CommitsBetween returns the commits reachable from the head revision, but
+// not from the base revision, ordered by committer time from newest to
+// oldest. If base is empty, all commits reachable from head are returned.
+// In a shallow clone, the history ends at the shallow boundary.
+func (g *Client) CommitsBetween(base, head string) ([]*git.Commit, error) {
+	if g.repository == nil {
+		return nil, git.ErrNoGitRepository
+	}
+
+	headCommit, err := resolveCommit(g.repository, head)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve revision '%s': %w", head, err)
+	}
+
+	exclude := make(map[plumbing.Hash]struct{})
+	if base != "" {
+		baseCommit, err := resolveCommit(g.repository, base)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve revision '%s': %w", base, err)
+		}
+		if err = walkCommits(g.repository, baseCommit.Hash, nil, func(c *object.Commit) error {
+			exclude[c.Hash] = struct{}{}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("unable to walk history of '%s': %w", base, err)
+		}
+	}
+
+	var commits []*object.Commit
+	if err = walkCommits(g.repository, headCommit.Hash, exclude, func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to walk history of '%s': %w", head, err)
+	}
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+
+	result := make([]*git.Commit, 0, len(commits))
+	for _, c := range commits {
+		cc, err := buildCommitWithRef(c, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cc)
+	}
+	return result, nil
+}
+
+// ChangedFiles returns the files that changed between the trees of the base
+// and head revisions, ordered by path. If base is empty, all the files of
+// head are returned as added. Renames are reported as a deletion and an
+// addition.
+func (g *Client) ChangedFiles(base, head string) ([]git.FileChange, error) {
+	if g.repository == nil {
+		return nil, git.ErrNoGitRepository
+	}
+
+	headTree, err := revisionTree(g.repository, head)
+	if err != nil {
+		return nil, err
+	}
+	var baseTree *object.Tree
+	if base != "" {
+		if baseTree, err = revisionTree(g.repository, base); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, fmt.Errorf("unable to diff '%s' and '%s': %w", base, head, err)
+	}
+
+	result := make([]git.FileChange, 0, len(changes))
+	for _, c := range changes {
+		action, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			result = append(result, git.FileChange{Path: c.To.Name, Action: git.ChangeActionAdd})
+		case merkletrie.Delete:
+			result = append(result, git.FileChange{Path: c.From.Name, Action: git.ChangeActionDelete})
+		case merkletrie.Modify:
+			result = append(result, git.FileChange{Path: c.To.Name, Action: git.ChangeActionModify})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+// lastObservedRef is the reference the last observed commit is temporarily
+// fetched into, if it is not part of the cloned history.
+const lastObservedRef = "refs/fetch/last-observed"
+
+// hasRelevantChanges reports whether any of the given paths changed between
+// the last observed revision and the head commit. If the last observed
+// commit is not part of the cloned history, for example due to a shallow
+// clone, it is fetched from the remote at url. If it can not be found, all
+// changes are assumed to be relevant.
+func (g *Client) hasRelevantChanges(ctx context.Context, url, lastObserved, head string, paths []string) (bool, error) {
+	hash := plumbing.NewHash(git.ExtractHashFromRevision(lastObserved).String())
+	if hash.IsZero() {
+		return true, nil
+	}
+	if _, err := g.repository.CommitObject(hash); err != nil {
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return false, fmt.Errorf("unable to resolve last observed commit '%s': %w", hash, err)
+		}
+		if err = g.fetchCommit(ctx, url, hash); err != nil {
+			return true, nil
+		}
+	}
+
+	changes, err := g.ChangedFiles(hash.String(), head)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range changes {
+		if inPaths(c.Path, paths) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fetchCommit fetches the commit with the given hash, without its history,
+// from the remote at url. This requires the server to allow fetching
+// commits by their hash.
+func (g *Client) fetchCommit(ctx context.Context, url string, hash plumbing.Hash) error {
+	remote := extgogit.NewRemote(g.repository.Storer, &config.RemoteConfig{
+		Name: git.DefaultRemote,
+		URLs: []string{url},
+	})
+	err := g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		return remote.FetchContext(ctx, &extgogit.FetchOptions{
+			RefSpecs:     []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", hash, lastObservedRef))},
+			Depth:        1,
+			Auth:         authMethod,
+			Tags:         extgogit.NoTags,
+			CABundle:     caBundle(g.authOpts),
+			ClientCert:   clientCert(g.authOpts),
+			ClientKey:    clientKey(g.authOpts),
+			ProxyOptions: g.proxy,
+		})
+	})
+	if err != nil && err != extgogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("unable to fetch commit '%s': %w", hash, goGitError(err))
+	}
+	return g.repository.Storer.RemoveReference(lastObservedRef)
+}
+
+// revisionTree returns the tree of the commit the given revision resolves
+// to.
+func revisionTree(repo *extgogit.Repository, rev string) (*object.Tree, error) {
+	c, err := resolveCommit(repo, rev)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve tree of '%s': %w", rev, err)
+	}
+	return tree, nil
+}
+
+// walkCommits calls fn for every commit reachable from the given hash,
+// except for the commits in exclude and their ancestors. Parents missing
+// from the repository, for example due to a shallow clone, are skipped.
+func walkCommits(repo *extgogit.Repository, from plumbing.Hash, exclude map[plumbing.Hash]struct{}, fn func(*object.Commit) error) error {
+	seen := make(map[plumbing.Hash]struct{})
+	queue := []plumbing.Hash{from}
+	for len(queue) > 0 {
+		h := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		if _, ok := exclude[h]; ok {
+			continue
+		}
+
+		c, err := repo.CommitObject(h)
+		if err != nil {
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				continue
+			}
+			return err
+		}
+		if err = fn(c); err != nil {
+			return err
+		}
+		queue = append(queue, c.ParentHashes...)
+	}
+	return nil
+}
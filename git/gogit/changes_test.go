@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestClient_CommitsBetween(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	now := time.Now()
+	first, err := commitFile(repo, "first", "first", now.Add(-3*time.Hour))
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := commitFile(repo, "second", "second", now.Add(-2*time.Hour))
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, second, true, "v1.0.0", now.Add(-2*time.Hour))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	signer, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	third, err := wt.Commit("Signed commit", &extgogit.CommitOptions{
+		Author:            mockSignature(now.Add(-time.Hour)),
+		Committer:         mockSignature(now.Add(-time.Hour)),
+		SignKey:           signer,
+		AllowEmptyCommits: true,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	// Tags are not cloned with a branch.
+	_, err = ggc.Fetch(context.TODO(), repoPath, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Tag: "v1.0.0"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tests := []struct {
+		name    string
+		base    string
+		head    string
+		want    []plumbing.Hash
+		wantErr string
+	}{
+		{
+			name: "between hashes",
+			base: first.String(),
+			head: third.String(),
+			want: []plumbing.Hash{third, second},
+		},
+		{
+			name: "between tag and branch",
+			base: "v1.0.0",
+			head: "master",
+			want: []plumbing.Hash{third},
+		},
+		{
+			name: "without base",
+			head: "v1.0.0",
+			want: []plumbing.Hash{second, first},
+		},
+		{
+			name: "same revision",
+			base: "master",
+			head: third.String(),
+		},
+		{
+			name: "head is ancestor of base",
+			base: third.String(),
+			head: first.String(),
+		},
+		{
+			name:    "unknown revision",
+			base:    "master",
+			head:    "unknown",
+			wantErr: "unable to resolve revision 'unknown'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			commits, err := ggc.CommitsBetween(tt.base, tt.head)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+
+			var got []plumbing.Hash
+			for _, c := range commits {
+				got = append(got, plumbing.NewHash(c.Hash.String()))
+				g.Expect(git.IsConcreteCommit(*c)).To(BeTrue())
+			}
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+
+	commits, err := ggc.CommitsBetween(second.String(), third.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commits).To(HaveLen(1))
+	g.Expect(commits[0].Message).To(Equal("Signed commit"))
+	g.Expect(commits[0].Signature).To(HavePrefix("-----BEGIN PGP SIGNATURE-----"))
+}
+
+func TestClient_ChangedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	first, err := commitFile(repo, "apps/app.yaml", "app", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "apps/remove.yaml", "remove", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := commitFile(repo, "README.md", "readme", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = commitFile(repo, "apps/app.yaml", "changed", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "infra/infra.yaml", "infra", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = wt.Remove("apps/remove.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	head, err := wt.Commit("Remove file", &extgogit.CommitOptions{
+		Author: mockSignature(time.Now()),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	got, err := ggc.ChangedFiles(base.String(), head.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal([]git.FileChange{
+		{Path: "apps/app.yaml", Action: git.ChangeActionModify},
+		{Path: "apps/remove.yaml", Action: git.ChangeActionDelete},
+		{Path: "infra/infra.yaml", Action: git.ChangeActionAdd},
+	}))
+
+	got, err = ggc.ChangedFiles("", first.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(Equal([]git.FileChange{
+		{Path: "apps/app.yaml", Action: git.ChangeActionAdd},
+	}))
+
+	got, err = ggc.ChangedFiles("master", head.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got).To(BeEmpty())
+
+	_, err = ggc.ChangedFiles("unknown", "master")
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = (&Client{}).ChangedFiles(base.String(), head.String())
+	g.Expect(err).To(Equal(git.ErrNoGitRepository))
+}
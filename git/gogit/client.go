@@ -17,12 +17,16 @@ limitations under the License.
 package gogit
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
@@ -32,6 +36,7 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
 	"github.com/go-git/go-git/v5/plumbing/transport"
@@ -66,7 +71,8 @@ func init() {
 // ClientName is the string representation of Client.
 const ClientName = "go-git"
 
-// Client implements repository.Client.
+// Client implements repository.Client, repository.Fetcher,
+// repository.HistoryReader and repository.Tagger.
 type Client struct {
 	*repository.DiscardCloser
 	path                 string
@@ -78,9 +84,16 @@ type Client struct {
 	useDefaultKnownHosts bool
 	singleBranch         bool
 	proxy                transport.ProxyOptions
+	sparseCheckoutDirs   []string
+	submoduleAuthOpts    map[string]*git.AuthOptions
 }
 
-var _ repository.Client = &Client{}
+var (
+	_ repository.Client        = &Client{}
+	_ repository.Fetcher       = &Client{}
+	_ repository.HistoryReader = &Client{}
+	_ repository.Tagger        = &Client{}
+)
 
 type ClientOption func(*Client) error
 
@@ -205,6 +218,33 @@ func WithProxy(opts transport.ProxyOptions) ClientOption {
 	}
 }
 
+// WithSubmoduleAuthOptions configures the auth options used for the
+// submodules checked out by a clone with RecurseSubmodules set. The map is
+// keyed by the host, with an optional port, or the URL prefix of the
+// submodules. If multiple keys match the URL of a submodule, the longest
+// key is used. Submodules not matching any key use the auth options of
+// their parent repository if served by the same host and port, and are
+// fetched anonymously otherwise.
+//
+// URL prefixes are matched against the URL without credentials, in the
+// "<scheme>://<host>[:<port>]/<path>" form, and should end with a "/".
+// SCP-like URLs such as "git@github.com:org/repo" are therefore matched as
+// "ssh://github.com/org/repo".
+func WithSubmoduleAuthOptions(opts map[string]*git.AuthOptions) ClientOption {
+	return func(c *Client) error {
+		for key, o := range opts {
+			if o == nil {
+				return fmt.Errorf("nil auth options for submodules matching '%s'", key)
+			}
+			if err := o.Validate(); err != nil {
+				return fmt.Errorf("invalid auth options for submodules matching '%s': %w", key, err)
+			}
+		}
+		c.submoduleAuthOpts = opts
+		return nil
+	}
+}
+
 func (g *Client) Init(ctx context.Context, url, branch string) error {
 	if err := g.validateUrl(url); err != nil {
 		return err
@@ -250,36 +290,81 @@ func (g *Client) Clone(ctx context.Context, url string, cfg repository.CloneConf
 	if err := g.validateUrl(url); err != nil {
 		return nil, err
 	}
+	if err := validateSparseCheckout(cfg); err != nil {
+		return nil, err
+	}
+	err := validateClientCert(g.authOpts)
+	if err != nil {
+		return nil, err
+	}
 
+	var commit *git.Commit
 	checkoutStrat := cfg.CheckoutStrategy
 	switch {
 	case checkoutStrat.Commit != "":
-		return g.cloneCommit(ctx, url, checkoutStrat.Commit, cfg)
+		commit, err = g.cloneCommit(ctx, url, checkoutStrat.Commit, cfg)
 	case checkoutStrat.RefName != "":
-		return g.cloneRefName(ctx, url, checkoutStrat.RefName, cfg)
+		commit, err = g.cloneRefName(ctx, url, checkoutStrat.RefName, cfg)
 	case checkoutStrat.Tag != "":
-		return g.cloneTag(ctx, url, checkoutStrat.Tag, cfg)
+		commit, err = g.cloneTag(ctx, url, checkoutStrat.Tag, cfg)
 	case checkoutStrat.SemVer != "":
-		return g.cloneSemVer(ctx, url, checkoutStrat.SemVer, cfg)
+		commit, err = g.cloneSemVer(ctx, url, checkoutStrat.SemVer, cfg)
 	default:
 		branch := checkoutStrat.Branch
 		if branch == "" {
 			branch = git.DefaultBranch
 		}
-		return g.cloneBranch(ctx, url, branch, cfg)
+		commit, err = g.cloneBranch(ctx, url, branch, cfg)
 	}
+	if err != nil || commit == nil || !git.IsConcreteCommit(*commit) {
+		return commit, err
+	}
+	g.sparseCheckoutDirs = cfg.SparseCheckoutDirectories
+
+	if len(cfg.IncludePaths) > 0 && cfg.LastObservedCommit != "" {
+		relevant, err := g.hasRelevantChanges(ctx, url, cfg.LastObservedCommit, commit.Hash.String(), cfg.IncludePaths)
+		if err != nil {
+			return nil, err
+		}
+		if !relevant {
+			// Construct a non-concrete commit with the existing information.
+			return &git.Commit{
+				Hash:      commit.Hash,
+				Reference: commit.Reference,
+			}, nil
+		}
+	}
+
+	if cfg.RecurseSubmodules {
+		if err = g.updateSubmodules(ctx, g.repository, url, g.authOpts, cfg, int(extgogit.DefaultSubmoduleRecursionDepth)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.LFS {
+		if err = g.checkoutLFSObjects(ctx, url, cfg.LFSMaxSize); err != nil {
+			return nil, err
+		}
+	}
+	return commit, nil
 }
 
 func (g *Client) validateUrl(u string) error {
+	return g.validateUrlWithAuth(u, g.authOpts)
+}
+
+// validateUrlWithAuth validates the given URL for use with the given
+// git.AuthOptions.
+func (g *Client) validateUrlWithAuth(u string, authOpts *git.AuthOptions) error {
 	ru, err := url.Parse(u)
 	if err != nil {
 		return fmt.Errorf("cannot parse url: %w", err)
 	}
 
-	if g.authOpts != nil {
-		httpOrHttps := g.authOpts.Transport == git.HTTP || g.authOpts.Transport == git.HTTPS
-		hasUsernameOrPassword := g.authOpts.Username != "" || g.authOpts.Password != ""
-		hasBearerToken := g.authOpts.BearerToken != ""
+	if authOpts != nil {
+		httpOrHttps := authOpts.Transport == git.HTTP || authOpts.Transport == git.HTTPS
+		hasUsernameOrPassword := authOpts.Username != "" || authOpts.Password != ""
+		hasBearerToken := authOpts.BearerToken != ""
 
 		if httpOrHttps && hasBearerToken && hasUsernameOrPassword {
 			return errors.New("basic auth and bearer token cannot be set at the same time")
@@ -295,17 +380,191 @@ func (g *Client) validateUrl(u string) error {
 		return errors.New("URL cannot contain credentials when using HTTP")
 	}
 
-	if httpOrEmpty && g.authOpts != nil {
-		if g.authOpts.Username != "" || g.authOpts.Password != "" {
+	if httpOrEmpty && authOpts != nil {
+		if authOpts.Username != "" || authOpts.Password != "" {
 			return errors.New("basic auth cannot be sent over HTTP")
-		} else if g.authOpts.BearerToken != "" {
+		} else if authOpts.BearerToken != "" {
 			return errors.New("bearer token cannot be sent over HTTP")
+		} else if authOpts.CredentialsProvider != nil || authOpts.ProviderOpts != nil {
+			return errors.New("credentials provider cannot be used over HTTP")
 		}
 	}
 
 	return nil
 }
 
+// validateSparseCheckout validates the sparse checkout options of the given
+// repository.CloneConfig. Submodules can not be combined with a sparse
+// checkout, as they would be checked out regardless of the sparse checkout
+// directories.
+func validateSparseCheckout(cfg repository.CloneConfig) error {
+	if len(cfg.SparseCheckoutDirectories) > 0 && cfg.RecurseSubmodules {
+		return errors.New("sparse checkout directories cannot be combined with recursing submodules")
+	}
+	return nil
+}
+
+// buildObjectSignature returns the object.Signature for the given
+// git.Signature. If the signature has no time, it defaults to the given
+// time.
+func buildObjectSignature(s git.Signature, defaultWhen time.Time) *object.Signature {
+	when := s.When
+	if when.IsZero() {
+		when = defaultWhen
+	}
+	return &object.Signature{
+		Name:  s.Name,
+		Email: s.Email,
+		When:  when,
+	}
+}
+
+// appendTrailers appends the trailers to the message, skipping the ones
+// that are already present. Following Git, the trailers are separated from
+// the message by a blank line, unless the last paragraph of the message
+// already consists of trailers.
+func appendTrailers(msg string, trailers []repository.Trailer) string {
+	if len(trailers) == 0 {
+		return msg
+	}
+
+	msg = strings.TrimRight(msg, " \t\n")
+	paragraphs := strings.Split(msg, "\n\n")
+	last := strings.Split(paragraphs[len(paragraphs)-1], "\n")
+	existing := make(map[string]struct{})
+	isTrailerBlock := len(paragraphs) > 1
+	for _, l := range last {
+		existing[l] = struct{}{}
+		if !isTrailer(l) {
+			isTrailerBlock = false
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	separator := "\n\n"
+	if msg == "" {
+		separator = ""
+	} else if isTrailerBlock {
+		separator = "\n"
+	}
+	for _, t := range trailers {
+		line := t.String()
+		if _, ok := existing[line]; ok && isTrailerBlock {
+			continue
+		}
+		existing[line] = struct{}{}
+		b.WriteString(separator)
+		b.WriteString(line)
+		separator = "\n"
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// isTrailer reports whether the line is a "key: value" trailer.
+func isTrailer(line string) bool {
+	key, value, ok := strings.Cut(line, ": ")
+	if !ok || key == "" || strings.TrimSpace(value) == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveFile moves the file or directory at the given path to the new path,
+// and updates the index accordingly.
+func (g *Client) moveFile(wt *extgogit.Worktree, from, to string) error {
+	fi, err := g.worktreeFS.Lstat(from)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		_, err = wt.Move(from, to)
+		return err
+	}
+
+	if _, err = g.worktreeFS.Lstat(to); err == nil {
+		return extgogit.ErrDestinationExists
+	}
+	idx, err := g.repository.Storer.Index()
+	if err != nil {
+		return err
+	}
+	prefix := strings.Trim(filepath.ToSlash(filepath.Clean(from)), "/") + "/"
+	for _, e := range idx.Entries {
+		if name, ok := strings.CutPrefix(e.Name, prefix); ok {
+			if _, err = wt.Move(e.Name, filepath.Join(to, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return removeEmptyDirs(g.worktreeFS, from)
+}
+
+// sortedRenames returns the sorted source paths of the given renames, or
+// an error if the source or destination of a rename overlaps with that of
+// another, as the result would depend on the order of the renames.
+func sortedRenames(renames map[string]string) ([]string, error) {
+	cleanPath := func(p string) string {
+		return strings.Trim(path.Clean(filepath.ToSlash(p)), "/")
+	}
+	overlaps := func(a, b string) bool {
+		return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+	}
+
+	sources := make([]string, 0, len(renames))
+	for from := range renames {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+	for i, a := range sources {
+		aFrom, aTo := cleanPath(a), cleanPath(renames[a])
+		if overlaps(aFrom, aTo) {
+			return nil, fmt.Errorf("unable to rename '%s' to '%s': destination overlaps with source", a, renames[a])
+		}
+		for _, b := range sources[i+1:] {
+			bFrom, bTo := cleanPath(b), cleanPath(renames[b])
+			if overlaps(aFrom, bFrom) || overlaps(aFrom, bTo) || overlaps(aTo, bFrom) || overlaps(aTo, bTo) {
+				return nil, fmt.Errorf("unable to rename '%s' to '%s': conflicts with rename of '%s' to '%s'",
+					a, renames[a], b, renames[b])
+			}
+		}
+	}
+	return sources, nil
+}
+
+// removeEmptyDirs removes the given directory and its subdirectories, if
+// they do not contain any files.
+func removeEmptyDirs(fs billy.Filesystem, dir string) error {
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	empty := true
+	for _, e := range entries {
+		if !e.IsDir() {
+			empty = false
+			continue
+		}
+		sub := fs.Join(dir, e.Name())
+		if err = removeEmptyDirs(fs, sub); err != nil {
+			return err
+		}
+		if _, err = fs.Lstat(sub); err == nil {
+			empty = false
+		}
+	}
+	if !empty {
+		return nil
+	}
+	return fs.Remove(dir)
+}
+
 func (g *Client) writeFile(path string, reader io.Reader) error {
 	if g.repository == nil {
 		return git.ErrNoGitRepository
@@ -331,18 +590,33 @@ func (g *Client) Commit(info git.Commit, commitOpts ...repository.CommitOption)
 		o(options)
 	}
 
-	for path, content := range options.Files {
-		if err := g.writeFile(path, content); err != nil {
-			return "", err
-		}
+	wt, err := g.repository.Worktree()
+	if err != nil {
+		return "", err
 	}
 
-	wt, err := g.repository.Worktree()
+	for _, path := range options.DeletedFiles {
+		if _, err := wt.Remove(path); err != nil {
+			return "", fmt.Errorf("unable to delete '%s': %w", path, err)
+		}
+	}
+	renames, err := sortedRenames(options.RenamedFiles)
 	if err != nil {
 		return "", err
 	}
+	for _, from := range renames {
+		to := options.RenamedFiles[from]
+		if err := g.moveFile(wt, from, to); err != nil {
+			return "", fmt.Errorf("unable to rename '%s' to '%s': %w", from, to, err)
+		}
+	}
+	for path, content := range options.Files {
+		if err := g.writeFile(path, content); err != nil {
+			return "", err
+		}
+	}
 
-	status, err := wt.Status()
+	status, err := worktreeStatus(g.repository, wt)
 	if err != nil {
 		return "", err
 	}
@@ -361,34 +635,99 @@ func (g *Client) Commit(info git.Commit, commitOpts ...repository.CommitOption)
 		return head.Hash().String(), git.ErrNoStagedFiles
 	}
 
+	now := time.Now()
 	opts := &extgogit.CommitOptions{
-		Author: &object.Signature{
-			Name:  info.Author.Name,
-			Email: info.Author.Email,
-			When:  time.Now(),
-		},
+		Author: buildObjectSignature(info.Author, now),
+	}
+	// The committer defaults to the author.
+	if info.Committer.Name != "" || info.Committer.Email != "" {
+		opts.Committer = buildObjectSignature(info.Committer, now)
 	}
 
 	if options.Signer != nil {
 		opts.SignKey = options.Signer
 	}
 
-	commit, err := wt.Commit(info.Message, opts)
+	commit, err := wt.Commit(appendTrailers(info.Message, options.Trailers), opts)
 	if err != nil {
 		return "", err
 	}
 	return commit.String(), nil
 }
 
+// Tag creates a tag with the name of the provided info on the given commit,
+// or HEAD if commit is empty. The commit can be any revision that resolves
+// to a commit, like a hash or a branch name. If the info has a message, an
+// annotated tag is created, which can be signed using WithTagSigner.
+// Otherwise, a lightweight tag is created.
+func (g *Client) Tag(info git.Tag, commit string, tagOpts ...repository.TagOption) (*git.Tag, error) {
+	if g.repository == nil {
+		return nil, git.ErrNoGitRepository
+	}
+
+	options := &repository.TagOptions{}
+	for _, o := range tagOpts {
+		o(options)
+	}
+	if info.Name == "" {
+		return nil, errors.New("unable to create tag without a name")
+	}
+	if options.Signer != nil && info.Message == "" {
+		return nil, fmt.Errorf("unable to sign tag '%s': a message is required for a signed tag", info.Name)
+	}
+
+	if commit == "" {
+		commit = plumbing.HEAD.String()
+	}
+	cc, err := resolveCommit(g.repository, commit)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve commit '%s': %w", commit, err)
+	}
+
+	var opts *extgogit.CreateTagOptions
+	if info.Message != "" {
+		when := info.Author.When
+		if when.IsZero() {
+			when = time.Now()
+		}
+		opts = &extgogit.CreateTagOptions{
+			Tagger: &object.Signature{
+				Name:  info.Author.Name,
+				Email: info.Author.Email,
+				When:  when,
+			},
+			Message: info.Message,
+			SignKey: options.Signer,
+		}
+	}
+
+	ref, err := g.repository.CreateTag(info.Name, cc.Hash, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tag '%s': %w", info.Name, err)
+	}
+
+	var tagObj *object.Tag
+	if opts != nil {
+		if tagObj, err = g.repository.TagObject(ref.Hash()); err != nil {
+			return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", info.Name, ref.Hash(), err)
+		}
+	}
+	return buildTag(tagObj, ref.Name())
+}
+
 func (g *Client) Push(ctx context.Context, cfg repository.PushConfig) error {
 	if g.repository == nil {
 		return git.ErrNoGitRepository
 	}
+	if err := validateClientCert(g.authOpts); err != nil {
+		return err
+	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
+	remote, err := g.repository.Remote(extgogit.DefaultRemoteName)
 	if err != nil {
-		return fmt.Errorf("failed to construct auth method with options: %w", err)
+		return err
 	}
+	url := remote.Config().URLs[0]
 
 	var refspecs []config.RefSpec
 	for _, ref := range cfg.Refspecs {
@@ -398,6 +737,7 @@ func (g *Client) Push(ctx context.Context, cfg repository.PushConfig) error {
 	// If no refspecs were provided, we need to push the current ref HEAD points to.
 	// The format of a refspec for a Git push is generally something like
 	// "refs/heads/branch:refs/heads/branch".
+	var branch plumbing.ReferenceName
 	if len(refspecs) == 0 {
 		head, err := g.repository.Head()
 		if err != nil {
@@ -406,19 +746,117 @@ func (g *Client) Push(ctx context.Context, cfg repository.PushConfig) error {
 
 		headRefspec := config.RefSpec(fmt.Sprintf("%s:%[1]s", head.Name()))
 		refspecs = append(refspecs, headRefspec)
+		if head.Name().IsBranch() {
+			branch = head.Name()
+		}
+	}
+	for _, ref := range cfg.NotesRefs {
+		name, err := notesRefName(ref)
+		if err != nil {
+			return err
+		}
+		refspecs = append(refspecs, config.RefSpec(fmt.Sprintf("%s:%[1]s", name)))
 	}
 
-	err = g.repository.PushContext(ctx, &extgogit.PushOptions{
-		RefSpecs:     refspecs,
-		Force:        cfg.Force,
-		RemoteName:   extgogit.DefaultRemoteName,
-		Auth:         authMethod,
-		Progress:     nil,
-		CABundle:     caBundle(g.authOpts),
-		ProxyOptions: g.proxy,
-		Options:      cfg.Options,
-	})
-	return goGitError(err)
+	for attempt := 0; ; attempt++ {
+		err = g.withAuth(ctx, url, git.OperationWrite, func(authMethod transport.AuthMethod) error {
+			// The remote writes messages, such as the output of hooks, to
+			// the progress sideband.
+			var progress bytes.Buffer
+			err := g.repository.PushContext(ctx, &extgogit.PushOptions{
+				RefSpecs:     refspecs,
+				Force:        cfg.Force,
+				RemoteName:   extgogit.DefaultRemoteName,
+				Auth:         authMethod,
+				Progress:     &progress,
+				CABundle:     caBundle(g.authOpts),
+				ClientCert:   clientCert(g.authOpts),
+				ClientKey:    clientKey(g.authOpts),
+				ProxyOptions: g.proxy,
+				Options:      cfg.Options,
+			})
+			return pushError(err, progress.String())
+		})
+		var rejected git.ErrPushRejected
+		if cfg.Force || branch == "" || attempt >= cfg.RebaseRetries ||
+			!errors.As(err, &rejected) || rejected.Reason != git.PushRejectedNonFastForward ||
+			(rejected.Reference != "" && rejected.Reference != branch.String()) {
+			return err
+		}
+		if err = g.rebaseOnRemote(ctx, url, branch, cfg.Signer); err != nil {
+			return err
+		}
+	}
+}
+
+// pushError returns a git.ErrPushRejected for the given push error, if the
+// reason of the rejection can be determined from the error or the messages
+// of the remote. Otherwise, it returns the error as-is.
+func pushError(err error, remoteOutput string) error {
+	if err == nil || err == extgogit.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	var messages []string
+	for _, line := range strings.Split(remoteOutput, "\n") {
+		// Progress updates overwrite the line using carriage returns.
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			messages = append(messages, line)
+		}
+	}
+
+	// Errors of the report status of the remote are formatted as
+	// "command error on <ref>: <status>", while the local fast-forward
+	// check results in "non-fast-forward update: <ref>".
+	msg := err.Error()
+	var ref, status string
+	if rest, ok := strings.CutPrefix(msg, "command error on "); ok {
+		ref, status, _ = strings.Cut(rest, ": ")
+	} else if rest, ok := strings.CutPrefix(msg, "non-fast-forward update: "); ok {
+		ref, status = rest, "non-fast-forward"
+	} else {
+		status = msg
+	}
+	status = strings.ToLower(status)
+
+	rejected := git.ErrPushRejected{
+		Reference:      ref,
+		RemoteMessages: messages,
+		Err:            err,
+	}
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		strings.Contains(status, "unable to authenticate"):
+		rejected.Reason = git.PushRejectedAuthentication
+	case strings.Contains(status, "non-fast-forward"), strings.Contains(status, "fetch first"):
+		rejected.Reason = git.PushRejectedNonFastForward
+	case strings.Contains(status, "protected branch"),
+		strings.Contains(status, "hook declined") && containsFold(messages, "protected branch"):
+		rejected.Reason = git.PushRejectedProtectedBranch
+	case strings.Contains(status, "hook declined"):
+		rejected.Reason = git.PushRejectedHookDeclined
+	case strings.Contains(status, "failed to lock"), strings.Contains(status, "cannot lock ref"):
+		rejected.Reason = git.PushRejectedRefLock
+	default:
+		return goGitError(err)
+	}
+	return rejected
+}
+
+// containsFold returns true if any of the lines contains substr, ignoring
+// case.
+func containsFold(lines []string, substr string) bool {
+	substr = strings.ToLower(substr)
+	for _, l := range lines {
+		if strings.Contains(strings.ToLower(l), substr) {
+			return true
+		}
+	}
+	return false
 }
 
 // SwitchBranch switches the current branch to the given branch name.
@@ -510,13 +948,81 @@ func (g *Client) IsClean() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	status, err := wt.Status()
+	status, err := worktreeStatus(g.repository, wt)
 	if err != nil {
 		return false, err
 	}
 	return status.IsClean(), nil
 }
 
+// worktreeStatus returns the status of the worktree, without the files
+// that are excluded from a sparse checkout, or that contain the Git LFS
+// object of their pointer.
+// As go-git does not reliably compare an index with skip-worktree entries
+// against the worktree, the status is computed against an in-memory copy
+// of the index without them. The index of the repository is not modified.
+func worktreeStatus(repo *extgogit.Repository, wt *extgogit.Worktree) (extgogit.Status, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := make(map[string]struct{})
+	sparse := &index.Index{Version: idx.Version, Cache: idx.Cache, ResolveUndo: idx.ResolveUndo, EndOfIndexEntry: idx.EndOfIndexEntry}
+	for _, e := range idx.Entries {
+		if e.SkipWorktree {
+			skipped[e.Name] = struct{}{}
+			continue
+		}
+		sparse.Entries = append(sparse.Entries, e)
+	}
+
+	if len(skipped) > 0 {
+		r, err := extgogit.Open(&statusStorer{Storer: repo.Storer, idx: sparse}, wt.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		if wt, err = r.Worktree(); err != nil {
+			return nil, err
+		}
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	for name := range skipped {
+		delete(status, name)
+	}
+
+	// Files replaced with the Git LFS object their pointer refers to are
+	// unmodified.
+	for name, s := range status {
+		if s.Staging != extgogit.Unmodified || s.Worktree != extgogit.Modified {
+			continue
+		}
+		if e, err := idx.Entry(name); err == nil && isLFSObject(repo, wt.Filesystem, e) {
+			delete(status, name)
+		}
+	}
+	return status, nil
+}
+
+// statusStorer is a storage.Storer which serves the given index instead of
+// the index of the underlying storage, and refuses to write an index.
+type statusStorer struct {
+	storage.Storer
+	idx *index.Index
+}
+
+func (s *statusStorer) Index() (*index.Index, error) {
+	return s.idx, nil
+}
+
+func (s *statusStorer) SetIndex(*index.Index) error {
+	return errors.New("unable to write index: read-only storage")
+}
+
 func (g *Client) Head() (string, error) {
 	if g.repository == nil {
 		return "", git.ErrNoGitRepository
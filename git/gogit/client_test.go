@@ -17,7 +17,9 @@ limitations under the License.
 package gogit
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,8 +27,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-billy/v5"
 	extgogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	. "github.com/onsi/gomega"
 
 	"github.com/fluxcd/pkg/git"
@@ -173,6 +180,259 @@ func TestCommit(t *testing.T) {
 	g.Expect(cc).ToNot(Equal(hash))
 }
 
+func TestCommit_DeletedAndRenamedFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+
+	err = server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	tmp := t.TempDir()
+	repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+		URL: filepath.Join(server.Root(), "test.git"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(tmp, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+
+	author := git.Signature{
+		Name:  "Test User",
+		Email: "test@example.com",
+	}
+	_, err = ggc.Commit(git.Commit{Author: author, Message: "Add files"},
+		repository.WithFiles(map[string]io.Reader{
+			"apps/app.yaml":      strings.NewReader("app"),
+			"apps/obsolete.yaml": strings.NewReader("obsolete"),
+			"old/a.yaml":         strings.NewReader("a"),
+			"old/nested/b.yaml":  strings.NewReader("b"),
+		}),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// A commit with only deletions.
+	cc, err := ggc.Commit(git.Commit{Author: author, Message: "Delete files"},
+		repository.WithDeletedFiles("apps/obsolete.yaml", "foo.txt"),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(treeFiles(g, repo, cc)).To(ConsistOf("apps/app.yaml", "old/a.yaml", "old/nested/b.yaml"))
+	g.Expect(filepath.Join(tmp, "apps/obsolete.yaml")).ToNot(BeAnExistingFile())
+
+	// Renames of a file and a directory, with a changed content.
+	cc, err = ggc.Commit(git.Commit{Author: author, Message: "Rename files"},
+		repository.WithRenamedFiles(map[string]string{
+			"apps/app.yaml": "apps/renamed.yaml",
+			"old":           "new",
+		}),
+		repository.WithFiles(map[string]io.Reader{
+			"new/a.yaml": strings.NewReader("changed"),
+		}),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(treeFiles(g, repo, cc)).To(ConsistOf("apps/renamed.yaml", "new/a.yaml", "new/nested/b.yaml"))
+	g.Expect(filepath.Join(tmp, "old")).ToNot(BeAnExistingFile())
+	g.Expect(os.ReadFile(filepath.Join(tmp, "new/a.yaml"))).To(BeEquivalentTo("changed"))
+
+	clean, err := ggc.IsClean()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(clean).To(BeTrue())
+
+	// A directory can be deleted.
+	cc, err = ggc.Commit(git.Commit{Author: author, Message: "Delete directory"},
+		repository.WithDeletedFiles("new"),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(treeFiles(g, repo, cc)).To(ConsistOf("apps/renamed.yaml"))
+
+	g.Expect(os.WriteFile(filepath.Join(tmp, "exists.yaml"), []byte("exists"), 0o644)).To(Succeed())
+	_, err = ggc.Commit(git.Commit{Author: author, Message: "Rename to existing"},
+		repository.WithRenamedFiles(map[string]string{"apps/renamed.yaml": "exists.yaml"}),
+	)
+	g.Expect(err).To(MatchError(ContainSubstring("unable to rename 'apps/renamed.yaml' to 'exists.yaml'")))
+}
+
+func Test_sortedRenames(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "independent renames",
+			renames: map[string]string{"b": "d", "a": "c", "apps/app.yaml": "apps-2/app.yaml"},
+			want:    []string{"a", "apps/app.yaml", "b"},
+		},
+		{
+			name:    "chained renames",
+			renames: map[string]string{"a": "b", "b": "c"},
+			wantErr: "unable to rename 'a' to 'b': conflicts with rename of 'b' to 'c'",
+		},
+		{
+			name:    "swapped renames",
+			renames: map[string]string{"a": "b", "b": "a"},
+			wantErr: "conflicts with rename",
+		},
+		{
+			name:    "same destination",
+			renames: map[string]string{"a": "c", "b": "./c"},
+			wantErr: "conflicts with rename",
+		},
+		{
+			name:    "rename within renamed directory",
+			renames: map[string]string{"apps": "new", "apps/app.yaml": "app.yaml"},
+			wantErr: "conflicts with rename",
+		},
+		{
+			name:    "rename into renamed directory",
+			renames: map[string]string{"apps": "new", "app.yaml": "apps/app.yaml"},
+			wantErr: "conflicts with rename",
+		},
+		{
+			name:    "rename into itself",
+			renames: map[string]string{"apps": "apps/nested"},
+			wantErr: "unable to rename 'apps' to 'apps/nested': destination overlaps with source",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// Run repeatedly, as the map iteration order is random.
+			for i := 0; i < 10; i++ {
+				got, err := sortedRenames(tt.renames)
+				if tt.wantErr != "" {
+					g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+					continue
+				}
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(got).To(Equal(tt.want))
+			}
+		})
+	}
+}
+
+func TestCommit_CommitterAndTrailers(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "test", "initial", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), nil, WithMemoryStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+	ggc.worktreeFS = func() billy.Filesystem {
+		wt, err := repo.Worktree()
+		g.Expect(err).ToNot(HaveOccurred())
+		return wt.Filesystem
+	}()
+
+	author := git.Signature{
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		When:  time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	committer := git.Signature{
+		Name:  "Flux Bot",
+		Email: "bot@example.com",
+		When:  time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC),
+	}
+	cc, err := ggc.Commit(git.Commit{Author: author, Committer: committer, Message: "Update image"},
+		repository.WithFiles(map[string]io.Reader{"test": strings.NewReader("changed")}),
+		repository.WithTrailers(
+			repository.Trailer{Key: "Signed-off-by", Value: "Jane Doe <jane@example.com>"},
+			repository.Trailer{Key: "Co-authored-by", Value: "John Doe <john@example.com>"},
+		),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	commit, err := repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.Author.Name).To(Equal(author.Name))
+	g.Expect(commit.Author.When.Equal(author.When)).To(BeTrue())
+	g.Expect(commit.Committer.Name).To(Equal(committer.Name))
+	g.Expect(commit.Committer.Email).To(Equal(committer.Email))
+	g.Expect(commit.Committer.When.Equal(committer.When)).To(BeTrue())
+	g.Expect(commit.Message).To(Equal("Update image\n\nSigned-off-by: Jane Doe <jane@example.com>\nCo-authored-by: John Doe <john@example.com>\n"))
+
+	// The committer defaults to the author, and the time to now.
+	cc, err = ggc.Commit(git.Commit{Author: git.Signature{Name: "Jane Doe", Email: "jane@example.com"}, Message: "Update"},
+		repository.WithFiles(map[string]io.Reader{"test": strings.NewReader("changed again")}),
+	)
+	g.Expect(err).ToNot(HaveOccurred())
+	commit, err = repo.CommitObject(plumbing.NewHash(cc))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commit.Committer.Name).To(Equal("Jane Doe"))
+	g.Expect(commit.Author.When).To(BeTemporally("~", time.Now(), time.Minute))
+	g.Expect(commit.Message).To(Equal("Update"))
+}
+
+func Test_appendTrailers(t *testing.T) {
+	signOff := repository.Trailer{Key: "Signed-off-by", Value: "Jane Doe <jane@example.com>"}
+	coAuthor := repository.Trailer{Key: "Co-authored-by", Value: "John Doe <john@example.com>"}
+
+	tests := []struct {
+		name     string
+		msg      string
+		trailers []repository.Trailer
+		want     string
+	}{
+		{
+			name: "without trailers",
+			msg:  "Update image",
+			want: "Update image",
+		},
+		{
+			name:     "subject only",
+			msg:      "Update image\n",
+			trailers: []repository.Trailer{signOff},
+			want:     "Update image\n\nSigned-off-by: Jane Doe <jane@example.com>\n",
+		},
+		{
+			name:     "with body",
+			msg:      "Update image\n\nBump to v1.0.0.",
+			trailers: []repository.Trailer{signOff, coAuthor},
+			want:     "Update image\n\nBump to v1.0.0.\n\nSigned-off-by: Jane Doe <jane@example.com>\nCo-authored-by: John Doe <john@example.com>\n",
+		},
+		{
+			name:     "with existing trailers",
+			msg:      "Update image\n\nSigned-off-by: Jane Doe <jane@example.com>\n",
+			trailers: []repository.Trailer{signOff, coAuthor},
+			want:     "Update image\n\nSigned-off-by: Jane Doe <jane@example.com>\nCo-authored-by: John Doe <john@example.com>\n",
+		},
+		{
+			name:     "empty message",
+			trailers: []repository.Trailer{signOff},
+			want:     "Signed-off-by: Jane Doe <jane@example.com>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(appendTrailers(tt.msg, tt.trailers)).To(Equal(tt.want))
+		})
+	}
+}
+
+// treeFiles returns the names of the files in the tree of the given commit.
+func treeFiles(g *WithT, repo *extgogit.Repository, commit string) []string {
+	c, err := repo.CommitObject(plumbing.NewHash(commit))
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err := c.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	var files []string
+	g.Expect(tree.Files().ForEach(func(f *object.File) error {
+		files = append(files, f.Name)
+		return nil
+	})).To(Succeed())
+	return files
+}
+
 func TestPush(t *testing.T) {
 	g := NewWithT(t)
 
@@ -182,11 +442,11 @@ func TestPush(t *testing.T) {
 	defer server.StopHTTP()
 
 	tmp := t.TempDir()
-	auth, err := transportAuth(&git.AuthOptions{
+	auth, err := transportAuth(context.TODO(), &git.AuthOptions{
 		Transport: git.HTTP,
 		Username:  "test-user",
 		Password:  "test-pass",
-	}, false)
+	}, git.CredentialsRequest{}, false, true)
 	g.Expect(err).ToNot(HaveOccurred())
 
 	repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
@@ -392,6 +652,374 @@ func TestForcePush(t *testing.T) {
 	g.Expect(ref.Hash().String()).To(Equal(cc2.String()))
 }
 
+func TestPush_RebaseRetries(t *testing.T) {
+	g := NewWithT(t)
+
+	server, repoURL, err := setupGitServer(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	defer server.StopHTTP()
+
+	clone := func() *Client {
+		ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithDiskStorage())
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+		return ggc
+	}
+	commit := func(ggc *Client, path, content string) string {
+		cc, err := ggc.Commit(git.Commit{
+			Author:  git.Signature{Name: "Jane Doe", Email: "jane@example.com"},
+			Message: "Update " + path,
+		}, repository.WithFiles(map[string]io.Reader{path: strings.NewReader(content)}))
+		g.Expect(err).ToNot(HaveOccurred())
+		return cc
+	}
+
+	ggc1, ggc2 := clone(), clone()
+
+	first := commit(ggc1, "apps/first.yaml", "first")
+	g.Expect(ggc1.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+
+	// Without retries, the push is rejected.
+	commit(ggc2, "apps/second.yaml", "second")
+	commit(ggc2, "infra/second.yaml", "second")
+	err = ggc2.Push(context.TODO(), repository.PushConfig{})
+	var rejected git.ErrPushRejected
+	g.Expect(errors.As(err, &rejected)).To(BeTrue())
+	g.Expect(rejected.Reason).To(Equal(git.PushRejectedNonFastForward))
+
+	// With retries, the local commits are re-applied on top of the remote.
+	g.Expect(ggc2.Push(context.TODO(), repository.PushConfig{RebaseRetries: 1})).To(Succeed())
+
+	head, err := ggc2.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	commits, err := ggc2.CommitsBetween(first, head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(commits).To(HaveLen(2))
+	g.Expect(commits[0].Message).To(Equal("Update infra/second.yaml"))
+	g.Expect(commits[1].Message).To(Equal("Update apps/second.yaml"))
+	g.Expect(commits[0].Author.Name).To(Equal("Jane Doe"))
+	for _, f := range []string{"apps/first.yaml", "apps/second.yaml", "infra/second.yaml"} {
+		g.Expect(filepath.Join(ggc2.Path(), f)).To(BeAnExistingFile())
+	}
+	clean, err := ggc2.IsClean()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(clean).To(BeTrue())
+
+	verify := clone()
+	remoteHead, err := verify.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remoteHead).To(Equal(head))
+
+	// Conflicting changes to the same path are reported.
+	commit(ggc1, "apps/first.yaml", "changed by first")
+	commit(verify, "apps/first.yaml", "changed by verify")
+	g.Expect(verify.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+
+	err = ggc1.Push(context.TODO(), repository.PushConfig{RebaseRetries: 3})
+	g.Expect(err).To(HaveOccurred())
+	var conflictErr git.ErrRebaseConflict
+	g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+	g.Expect(conflictErr.Branch).To(Equal(git.DefaultBranch))
+	g.Expect(conflictErr.Paths).To(Equal([]string{"apps/first.yaml"}))
+}
+
+func TestPush_RebaseRetriesSignedCommits(t *testing.T) {
+	g := NewWithT(t)
+
+	server, repoURL, err := setupGitServer(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	defer server.StopHTTP()
+
+	clone := func() *Client {
+		ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithDiskStorage())
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+		return ggc
+	}
+	signer, keyRing := newKeyRing(t, "Test User")
+	commit := func(ggc *Client, path string) string {
+		cc, err := ggc.Commit(git.Commit{
+			Author:  git.Signature{Name: "Jane Doe", Email: "jane@example.com"},
+			Message: "Update " + path,
+		}, repository.WithFiles(map[string]io.Reader{path: strings.NewReader(path)}), repository.WithSigner(signer))
+		g.Expect(err).ToNot(HaveOccurred())
+		return cc
+	}
+
+	ggc1, ggc2 := clone(), clone()
+
+	first := commit(ggc1, "apps/first.yaml")
+	g.Expect(ggc1.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+	second := commit(ggc2, "apps/second.yaml")
+
+	// Without a signer, the signed commit is not rebased.
+	err = ggc2.Push(context.TODO(), repository.PushConfig{RebaseRetries: 1})
+	var signedErr git.ErrRebaseSignedCommit
+	g.Expect(errors.As(err, &signedErr)).To(BeTrue(), "unexpected error: %v", err)
+	g.Expect(signedErr.Branch).To(Equal(git.DefaultBranch))
+	g.Expect(signedErr.Commit).To(Equal(second))
+	head, err := ggc2.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(head).To(Equal(second))
+
+	// With a signer, the re-applied commit is signed again.
+	g.Expect(ggc2.Push(context.TODO(), repository.PushConfig{RebaseRetries: 1, Signer: signer})).To(Succeed())
+	head, err = ggc2.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(head).ToNot(Equal(second))
+	cc, err := ggc2.VerifyCommits(context.TODO(), first, []string{keyRing})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc).To(BeNil())
+}
+
+func TestPush_rejections(t *testing.T) {
+	tests := []struct {
+		name         string
+		hook         string
+		password     string
+		wantReason   git.PushRejectionReason
+		wantMessages []string
+	}{
+		{
+			name:       "hook declined",
+			hook:       "#!/bin/sh\necho 'commit message does not match policy' >&2\nexit 1\n",
+			wantReason: git.PushRejectedHookDeclined,
+			wantMessages: []string{
+				"commit message does not match policy",
+				"error: hook declined to update refs/heads/master",
+			},
+		},
+		{
+			name:       "protected branch",
+			hook:       "#!/bin/sh\necho 'You are not allowed to push code to protected branches on this project.' >&2\nexit 1\n",
+			wantReason: git.PushRejectedProtectedBranch,
+		},
+		{
+			name:       "authentication failed",
+			password:   "wrong-pass",
+			wantReason: git.PushRejectedAuthentication,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			server, err := gittestserver.NewTempGitServer()
+			g.Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(server.Root())
+			server.Auth("test-user", "test-pass")
+			if tt.hook != "" {
+				server.InstallUpdateHook(tt.hook)
+			}
+			g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+			g.Expect(server.StartHTTP()).To(Succeed())
+			defer server.StopHTTP()
+
+			authOpts := &git.AuthOptions{
+				Transport: git.HTTP,
+				Username:  "test-user",
+				Password:  "test-pass",
+			}
+			ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage(), WithInsecureCredentialsOverHTTP())
+			g.Expect(err).ToNot(HaveOccurred())
+			_, err = ggc.Clone(context.TODO(), server.HTTPAddress()+"/test.git", repository.CloneConfig{})
+			g.Expect(err).ToNot(HaveOccurred())
+			_, err = commitFile(ggc.repository, "test", "testing gogit push", time.Now())
+			g.Expect(err).ToNot(HaveOccurred())
+
+			if tt.password != "" {
+				ggc.authOpts.Password = tt.password
+			}
+			err = ggc.Push(context.TODO(), repository.PushConfig{})
+			g.Expect(err).To(HaveOccurred())
+
+			var rejected git.ErrPushRejected
+			g.Expect(errors.As(err, &rejected)).To(BeTrue())
+			g.Expect(rejected.Reason).To(Equal(tt.wantReason))
+			if tt.wantMessages != nil {
+				g.Expect(rejected.Reference).To(Equal("refs/heads/master"))
+				g.Expect(rejected.RemoteMessages).To(Equal(tt.wantMessages))
+			}
+		})
+	}
+}
+
+func Test_pushError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		remoteOutput  string
+		wantReason    git.PushRejectionReason
+		wantReference string
+		wantMessages  []string
+	}{
+		{
+			name:          "local non-fast-forward check",
+			err:           errors.New("non-fast-forward update: refs/heads/main"),
+			wantReason:    git.PushRejectedNonFastForward,
+			wantReference: "refs/heads/main",
+		},
+		{
+			name:          "remote non-fast-forward",
+			err:           errors.New("command error on refs/heads/main: fetch first"),
+			wantReason:    git.PushRejectedNonFastForward,
+			wantReference: "refs/heads/main",
+		},
+		{
+			name:          "GitHub protected branch",
+			err:           errors.New("command error on refs/heads/main: protected branch hook declined"),
+			remoteOutput:  "error: GH006: Protected branch update failed for refs/heads/main.\n",
+			wantReason:    git.PushRejectedProtectedBranch,
+			wantReference: "refs/heads/main",
+			wantMessages:  []string{"error: GH006: Protected branch update failed for refs/heads/main."},
+		},
+		{
+			name:          "pre-receive hook declined",
+			err:           errors.New("command error on refs/heads/main: pre-receive hook declined"),
+			remoteOutput:  "Resolving deltas:  50% (1/2)\rResolving deltas: 100% (2/2), done.\n\nrejected by policy\n",
+			wantReason:    git.PushRejectedHookDeclined,
+			wantReference: "refs/heads/main",
+			wantMessages:  []string{"Resolving deltas: 100% (2/2), done.", "rejected by policy"},
+		},
+		{
+			name:          "ref lock failure",
+			err:           errors.New("command error on refs/heads/main: failed to lock"),
+			wantReason:    git.PushRejectedRefLock,
+			wantReference: "refs/heads/main",
+		},
+		{
+			name:       "authorization failed",
+			err:        transport.ErrAuthorizationFailed,
+			wantReason: git.PushRejectedAuthentication,
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("unexpected EOF"),
+		},
+		{
+			name: "already up-to-date",
+			err:  extgogit.NoErrAlreadyUpToDate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := pushError(tt.err, tt.remoteOutput)
+			var rejected git.ErrPushRejected
+			if tt.wantReason == "" {
+				g.Expect(errors.As(err, &rejected)).To(BeFalse())
+				g.Expect(err).To(Equal(tt.err))
+				return
+			}
+			g.Expect(errors.As(err, &rejected)).To(BeTrue())
+			g.Expect(rejected.Reason).To(Equal(tt.wantReason))
+			g.Expect(rejected.Reference).To(Equal(tt.wantReference))
+			g.Expect(rejected.RemoteMessages).To(Equal(tt.wantMessages))
+			g.Expect(errors.Is(err, tt.err)).To(BeTrue())
+		})
+	}
+}
+
+func TestTag(t *testing.T) {
+	g := NewWithT(t)
+
+	server, repoURL, err := setupGitServer(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	defer server.StopHTTP()
+
+	tmp := t.TempDir()
+	repo, err := extgogit.PlainClone(tmp, false, &extgogit.CloneOptions{
+		URL:        repoURL,
+		RemoteName: git.DefaultRemote,
+		Tags:       extgogit.NoTags,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(tmp, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+
+	first, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := commitFile(repo, "test", "testing gogit tag", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	signer, err := openpgp.NewEntity("Test User", "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	var keyRing bytes.Buffer
+	w, err := armor.Encode(&keyRing, openpgp.PublicKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(signer.Serialize(w)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+
+	tagger := git.Signature{
+		Name:  "Test User",
+		Email: "test@example.com",
+		When:  time.Now().Truncate(time.Second),
+	}
+
+	// Lightweight tag on HEAD.
+	tag, err := ggc.Tag(git.Tag{Name: "v0.1.0"}, "")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tag).To(Equal(&git.Tag{Name: "v0.1.0"}))
+	ref, err := repo.Reference(plumbing.NewTagReferenceName("v0.1.0"), false)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ref.Hash()).To(Equal(second))
+
+	// Annotated tag on a given commit.
+	tag, err = ggc.Tag(git.Tag{Name: "v0.0.1", Author: tagger, Message: "Release v0.0.1"}, first.Hash().String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tag.Name).To(Equal("v0.0.1"))
+	g.Expect(tag.Hash).ToNot(BeEmpty())
+	g.Expect(tag.Author.Name).To(Equal(tagger.Name))
+	g.Expect(tag.Author.When.Unix()).To(Equal(tagger.When.Unix()))
+	g.Expect(tag.Message).To(Equal("Release v0.0.1\n"))
+	g.Expect(tag.Signature).To(BeEmpty())
+	g.Expect(git.IsAnnotatedTag(*tag)).To(BeTrue())
+	tagObj, err := repo.TagObject(plumbing.NewHash(tag.Hash.String()))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tagObj.Target).To(Equal(first.Hash()))
+
+	// Signed tag on a branch.
+	signed, err := ggc.Tag(git.Tag{Name: "v0.2.0", Author: tagger, Message: "Release v0.2.0"}, git.DefaultBranch,
+		repository.WithTagSigner(signer))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(git.IsSignedTag(*signed)).To(BeTrue())
+	_, err = signed.Verify(keyRing.String())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Errors.
+	_, err = ggc.Tag(git.Tag{Name: "v0.1.0"}, "")
+	g.Expect(err).To(MatchError(ContainSubstring("tag already exists")))
+	_, err = ggc.Tag(git.Tag{Name: "v0.3.0"}, "", repository.WithTagSigner(signer))
+	g.Expect(err).To(MatchError(ContainSubstring("a message is required for a signed tag")))
+	_, err = ggc.Tag(git.Tag{Name: "v0.3.0"}, "unknown")
+	g.Expect(err).To(MatchError(ContainSubstring("unable to resolve commit 'unknown'")))
+	_, err = ggc.Tag(git.Tag{}, "")
+	g.Expect(err).To(HaveOccurred())
+
+	// Push the tags, and clone the signed tag.
+	err = ggc.Push(context.TODO(), repository.PushConfig{
+		Refspecs: []string{"refs/tags/*:refs/tags/*"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cloned, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	cc, err := cloned.Clone(context.TODO(), repoURL, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Tag: "v0.2.0"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(second.String()))
+	g.Expect(cc.ReferencingTag).To(Equal(signed))
+}
+
 func TestSwitchBranch(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -21,16 +21,22 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-billy/v5"
 	extgogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
 
 	"github.com/fluxcd/pkg/git"
@@ -38,21 +44,21 @@ import (
 	"github.com/fluxcd/pkg/version"
 )
 
-const tagDereferenceSuffix = "^{}"
+const (
+	tagDereferenceSuffix = "^{}"
+	// x509SignatureHeader is the header of an armored X.509 (CMS) signature.
+	x509SignatureHeader = "-----BEGIN SIGNED MESSAGE-----"
+)
 
 func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repository.CloneConfig) (*git.Commit, error) {
 	if g.authOpts == nil {
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
-	}
 
 	ref := plumbing.NewBranchReferenceName(branch)
 	// check if previous revision has changed before attempting to clone
 	if lastObserved := git.TransformRevision(opts.LastObservedCommit); lastObserved != "" {
-		head, err := g.getRemoteHEAD(ctx, url, ref, authMethod)
+		head, err := g.getRemoteHEAD(ctx, url, ref)
 		if err != nil {
 			return nil, err
 		}
@@ -73,21 +79,21 @@ func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repos
 		depth = 1
 	}
 	cloneOpts := &extgogit.CloneOptions{
-		URL:               url,
-		Auth:              authMethod,
-		RemoteName:        git.DefaultRemote,
-		ReferenceName:     plumbing.NewBranchReferenceName(branch),
-		SingleBranch:      g.singleBranch,
-		NoCheckout:        false,
-		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
-		Progress:          nil,
-		Tags:              extgogit.NoTags,
-		CABundle:          caBundle(g.authOpts),
-		ProxyOptions:      g.proxy,
-	}
-
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+		URL:           url,
+		RemoteName:    git.DefaultRemote,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		SingleBranch:  g.singleBranch,
+		NoCheckout:    len(opts.SparseCheckoutDirectories) > 0,
+		Depth:         depth,
+		Progress:      nil,
+		Tags:          extgogit.NoTags,
+		CABundle:      caBundle(g.authOpts),
+		ClientCert:    clientCert(g.authOpts),
+		ClientKey:     clientKey(g.authOpts),
+		ProxyOptions:  g.proxy,
+	}
+
+	repo, err := g.cloneContext(ctx, url, cloneOpts)
 	if err != nil {
 		if err == transport.ErrRepositoryNotFound || isRemoteBranchNotFoundErr(err, ref.String()) {
 			return nil, git.ErrRepositoryNotFound{
@@ -118,6 +124,11 @@ func (g *Client) cloneBranch(ctx context.Context, url, branch string, opts repos
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve commit object for HEAD '%s': %w", head.Hash(), err)
 	}
+	if len(opts.SparseCheckoutDirectories) > 0 {
+		if err = sparseCheckout(repo, cc, opts.SparseCheckoutDirectories); err != nil {
+			return nil, fmt.Errorf("unable to checkout branch '%s': %w", branch, err)
+		}
+	}
 	g.repository = repo
 	return buildCommitWithRef(cc, nil, ref)
 }
@@ -127,15 +138,10 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
-	}
-
 	ref := plumbing.NewTagReferenceName(tag)
 	// check if previous revision has changed before attempting to clone
 	if lastObserved := git.TransformRevision(opts.LastObservedCommit); lastObserved != "" {
-		head, err := g.getRemoteHEAD(ctx, url, ref, authMethod)
+		head, err := g.getRemoteHEAD(ctx, url, ref)
 		if err != nil {
 			return nil, err
 		}
@@ -156,22 +162,22 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 		depth = 1
 	}
 	cloneOpts := &extgogit.CloneOptions{
-		URL:               url,
-		Auth:              authMethod,
-		RemoteName:        git.DefaultRemote,
-		ReferenceName:     plumbing.NewTagReferenceName(tag),
-		SingleBranch:      g.singleBranch,
-		NoCheckout:        false,
-		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
-		Progress:          nil,
+		URL:           url,
+		RemoteName:    git.DefaultRemote,
+		ReferenceName: plumbing.NewTagReferenceName(tag),
+		SingleBranch:  g.singleBranch,
+		NoCheckout:    len(opts.SparseCheckoutDirectories) > 0,
+		Depth:         depth,
+		Progress:      nil,
 		// Ask for the tag object that points to the commit to be sent as well.
 		Tags:         extgogit.TagFollowing,
 		CABundle:     caBundle(g.authOpts),
+		ClientCert:   clientCert(g.authOpts),
+		ClientKey:    clientKey(g.authOpts),
 		ProxyOptions: g.proxy,
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, url, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound || isRemoteBranchNotFoundErr(err, ref.String()) {
 			return nil, git.ErrRepositoryNotFound{
@@ -190,6 +196,11 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve commit object for HEAD '%s': %w", head.Hash(), err)
 	}
+	if len(opts.SparseCheckoutDirectories) > 0 {
+		if err = sparseCheckout(repo, cc, opts.SparseCheckoutDirectories); err != nil {
+			return nil, fmt.Errorf("unable to checkout tag '%s': %w", tag, err)
+		}
+	}
 
 	tagRef, err := repo.Tag(tag)
 	if err != nil {
@@ -206,11 +217,6 @@ func (g *Client) cloneTag(ctx context.Context, url, tag string, opts repository.
 }
 
 func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repository.CloneConfig) (*git.Commit, error) {
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
-	}
-
 	// we only want to fetch tags if the refname provided is a tag
 	// and does not have the dereference suffix.
 	tagStrategy := extgogit.NoTags
@@ -218,23 +224,23 @@ func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repos
 		tagStrategy = extgogit.TagFollowing
 	}
 	cloneOpts := &extgogit.CloneOptions{
-		URL:               url,
-		Auth:              authMethod,
-		RemoteName:        git.DefaultRemote,
-		SingleBranch:      false,
-		NoCheckout:        true,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
-		Progress:          nil,
-		Tags:              tagStrategy,
-		CABundle:          caBundle(g.authOpts),
-		ProxyOptions:      g.proxy,
+		URL:          url,
+		RemoteName:   git.DefaultRemote,
+		SingleBranch: false,
+		NoCheckout:   true,
+		Progress:     nil,
+		Tags:         tagStrategy,
+		CABundle:     caBundle(g.authOpts),
+		ClientCert:   clientCert(g.authOpts),
+		ClientKey:    clientKey(g.authOpts),
+		ProxyOptions: g.proxy,
 	}
 	if opts.Branch != "" {
 		cloneOpts.SingleBranch = g.singleBranch
 		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, url, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound ||
 			isRemoteBranchNotFoundErr(err, cloneOpts.ReferenceName.String()) {
@@ -254,10 +260,17 @@ func (g *Client) cloneCommit(ctx context.Context, url, commit string, opts repos
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", commit, err)
 	}
-	err = w.Checkout(&extgogit.CheckoutOptions{
-		Hash:  cc.Hash,
-		Force: true,
-	})
+	if len(opts.SparseCheckoutDirectories) > 0 {
+		err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, cc.Hash))
+		if err == nil {
+			err = sparseCheckout(repo, cc, opts.SparseCheckoutDirectories)
+		}
+	} else {
+		err = w.Checkout(&extgogit.CheckoutOptions{
+			Hash:  cc.Hash,
+			Force: true,
+		})
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to checkout commit '%s': %w", commit, err)
 	}
@@ -276,28 +289,24 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		return nil, fmt.Errorf("semver parse error: %w", err)
 	}
 
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
-	}
 	var depth int
 	if opts.ShallowClone {
 		depth = 1
 	}
 	cloneOpts := &extgogit.CloneOptions{
-		URL:               url,
-		Auth:              authMethod,
-		RemoteName:        git.DefaultRemote,
-		NoCheckout:        false,
-		Depth:             depth,
-		RecurseSubmodules: recurseSubmodules(opts.RecurseSubmodules),
-		Progress:          nil,
-		Tags:              extgogit.AllTags,
-		CABundle:          caBundle(g.authOpts),
-		ProxyOptions:      g.proxy,
+		URL:          url,
+		RemoteName:   git.DefaultRemote,
+		NoCheckout:   len(opts.SparseCheckoutDirectories) > 0,
+		Depth:        depth,
+		Progress:     nil,
+		Tags:         extgogit.AllTags,
+		CABundle:     caBundle(g.authOpts),
+		ClientCert:   clientCert(g.authOpts),
+		ClientKey:    clientKey(g.authOpts),
+		ProxyOptions: g.proxy,
 	}
 
-	repo, err := extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+	repo, err := g.cloneContext(ctx, url, cloneOpts)
 	if err != nil {
 		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound {
 			return nil, git.ErrRepositoryNotFound{
@@ -308,12 +317,63 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		return nil, fmt.Errorf("unable to clone '%s': %w", url, goGitError(err))
 	}
 
+	t, err := latestSemVerTag(repo, semverTag, verConstraint)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open Git worktree: %w", err)
+	}
+
+	tagRef, err := repo.Tag(t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find reference for tag '%s': %w", t, err)
+	}
+	if len(opts.SparseCheckoutDirectories) > 0 {
+		var cc *object.Commit
+		if cc, err = peelCommit(repo, tagRef); err == nil {
+			if err = repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, cc.Hash)); err == nil {
+				err = sparseCheckout(repo, cc, opts.SparseCheckoutDirectories)
+			}
+		}
+	} else {
+		err = w.Checkout(&extgogit.CheckoutOptions{
+			Branch: tagRef.Name(),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to checkout tag '%s': %w", t, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve HEAD of tag '%s': %w", t, err)
+	}
+	cc, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve commit object for HEAD '%s': %w", head.Hash(), err)
+	}
+
+	tagObj, err := repo.TagObject(tagRef.Hash())
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", t, tagRef.Hash(), err)
+	}
+
+	g.repository = repo
+	return buildCommitWithRef(cc, tagObj, tagRef.Name())
+}
+
+// latestSemVerTag returns the name of the tag in the repository with the
+// highest version matching the given constraint.
+func latestSemVerTag(repo *extgogit.Repository, semverTag string, verConstraint *semver.Constraints) (string, error) {
 	repoTags, err := repo.Tags()
 	if err != nil {
-		return nil, fmt.Errorf("unable to list tags: %w", err)
+		return "", fmt.Errorf("unable to list tags: %w", err)
 	}
 
-	tags := make(map[string]string)
+	var tags []string
 	tagTimestamps := make(map[string]time.Time)
 	if err = repoTags.ForEach(func(t *plumbing.Reference) error {
 		revision := plumbing.Revision(t.Name().String())
@@ -327,14 +387,23 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		}
 		tagTimestamps[t.Name().Short()] = commit.Committer.When
 
-		tags[t.Name().Short()] = t.Strings()[1]
+		tags = append(tags, t.Name().Short())
 		return nil
 	}); err != nil {
-		return nil, err
+		return "", err
 	}
+	return latestSemVer(tags, tagTimestamps, semverTag, verConstraint)
+}
+
+// latestSemVer returns the tag with the highest version matching the given
+// constraint. Versions which are equal, i.e. only differ in build metadata,
+// are ordered by the timestamp of the tag, and then by name.
+func latestSemVer(tags []string, tagTimestamps map[string]time.Time, semverTag string, verConstraint *semver.Constraints) (string, error) {
+	tags = append([]string(nil), tags...)
+	sort.Strings(tags)
 
 	var matchedVersions semver.Collection
-	for tag := range tags {
+	for _, tag := range tags {
 		v, err := version.ParseVersion(tag)
 		if err != nil {
 			continue
@@ -345,7 +414,7 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		matchedVersions = append(matchedVersions, v)
 	}
 	if len(matchedVersions) == 0 {
-		return nil, fmt.Errorf("no match found for semver: %s", semverTag)
+		return "", fmt.Errorf("no match found for semver: %s", semverTag)
 	}
 
 	// Sort versions
@@ -363,52 +432,14 @@ func (g *Client) cloneSemVer(ctx context.Context, url, semverTag string, opts re
 		// a part of the comparable version in Semver
 		return tagTimestamps[left.Original()].Before(tagTimestamps[right.Original()])
 	})
-	v := matchedVersions[len(matchedVersions)-1]
-	t := v.Original()
-
-	w, err := repo.Worktree()
-	if err != nil {
-		return nil, fmt.Errorf("unable to open Git worktree: %w", err)
-	}
-
-	tagRef, err := repo.Tag(t)
-	if err != nil {
-		return nil, fmt.Errorf("unable to find reference for tag '%s': %w", t, err)
-	}
-	err = w.Checkout(&extgogit.CheckoutOptions{
-		Branch: tagRef.Name(),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("unable to checkout tag '%s': %w", t, err)
-	}
-
-	head, err := repo.Head()
-	if err != nil {
-		return nil, fmt.Errorf("unable to resolve HEAD of tag '%s': %w", t, err)
-	}
-	cc, err := repo.CommitObject(head.Hash())
-	if err != nil {
-		return nil, fmt.Errorf("unable to resolve commit object for HEAD '%s': %w", head.Hash(), err)
-	}
-
-	tagObj, err := repo.TagObject(tagRef.Hash())
-	if err != nil && err != plumbing.ErrObjectNotFound {
-		return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", t, tagRef.Hash(), err)
-	}
-
-	g.repository = repo
-	return buildCommitWithRef(cc, tagObj, tagRef.Name())
+	return matchedVersions[len(matchedVersions)-1].Original(), nil
 }
 
 func (g *Client) cloneRefName(ctx context.Context, url string, refName string, cloneOpts repository.CloneConfig) (*git.Commit, error) {
 	if g.authOpts == nil {
 		return nil, fmt.Errorf("unable to checkout repo with an empty set of auth options")
 	}
-	authMethod, err := transportAuth(g.authOpts, g.useDefaultKnownHosts)
-	if err != nil {
-		return nil, fmt.Errorf("unable to construct auth method with options: %w", err)
-	}
-	head, err := g.getRemoteHEAD(ctx, url, plumbing.ReferenceName(refName), authMethod)
+	head, err := g.getRemoteHEAD(ctx, url, plumbing.ReferenceName(refName))
 	if err != nil {
 		return nil, err
 	}
@@ -450,15 +481,182 @@ func (g *Client) cloneRefName(ctx context.Context, url string, refName string, c
 	return g.cloneCommit(ctx, url, hash.String(), cloneOpts)
 }
 
-func recurseSubmodules(recurse bool) extgogit.SubmoduleRescursivity {
-	if recurse {
-		return extgogit.DefaultSubmoduleRecursionDepth
+// peelCommit resolves the commit the given (tag) reference points to.
+func peelCommit(repo *extgogit.Repository, ref *plumbing.Reference) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref.Name().String()))
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(*hash)
+}
+
+// sparseCheckout writes the files of the given commit that are in one of
+// the given directories to the worktree, and records all files in the
+// index. Files outside the directories are marked with the skip-worktree
+// bit, which makes Git (and Client.Commit) treat them as unchanged. Files
+// of a previous checkout that are not part of the new one are removed.
+// It does not update HEAD.
+//
+// Worktree.ResetSparsely of go-git is not used, as it only marks the
+// entries of an existing index with the skip-worktree bit, and therefore
+// checks out all files into an empty worktree.
+func sparseCheckout(repo *extgogit.Repository, commit *object.Commit, dirs []string) error {
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("unable to open repo worktree: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("unable to resolve tree of commit '%s': %w", commit.Hash, err)
+	}
+
+	idx := &index.Index{Version: 2}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+
+		e := &index.Entry{
+			Name: name,
+			Hash: entry.Hash,
+			Mode: entry.Mode,
+		}
+		switch {
+		case !inPaths(name, dirs):
+			// Git requires version 3 of the index for extended flags.
+			e.SkipWorktree = true
+			idx.Version = 3
+		case entry.Mode == filemode.Submodule:
+			if err = w.Filesystem.MkdirAll(name, 0o755); err != nil {
+				return err
+			}
+		default:
+			f, err := tree.TreeEntryFile(&entry)
+			if err != nil {
+				return err
+			}
+			f.Name = name
+			if err = checkoutFile(w.Filesystem, f); err != nil {
+				return fmt.Errorf("unable to write file '%s': %w", name, err)
+			}
+			fi, err := w.Filesystem.Lstat(name)
+			if err != nil {
+				return err
+			}
+			e.Size = uint32(fi.Size())
+			e.ModifiedAt = fi.ModTime()
+		}
+		idx.Entries = append(idx.Entries, e)
+	}
+
+	// Remove the files of a previous checkout which are no longer part of
+	// the worktree.
+	if prev, err := repo.Storer.Index(); err == nil {
+		checkedOut := make(map[string]bool, len(idx.Entries))
+		for _, e := range idx.Entries {
+			checkedOut[e.Name] = !e.SkipWorktree
+		}
+		for _, e := range prev.Entries {
+			if e.SkipWorktree || checkedOut[e.Name] {
+				continue
+			}
+			if err := w.Filesystem.Remove(e.Name); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("unable to remove file '%s': %w", e.Name, err)
+			}
+		}
+	}
+	return repo.Storer.SetIndex(idx)
+}
+
+// checkoutFile writes the given file to the filesystem.
+func checkoutFile(fs billy.Filesystem, f *object.File) error {
+	mode, err := f.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if mode&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return fs.Symlink(string(target), f.Name)
+	}
+
+	to, err := fs.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
 	}
-	return extgogit.NoRecurseSubmodules
+	if _, err = io.Copy(to, r); err != nil {
+		_ = to.Close()
+		return err
+	}
+	return to.Close()
 }
 
-func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.ReferenceName,
-	authMethod transport.AuthMethod) (string, error) {
+// inPaths reports whether the file at the given path is one of the given
+// paths, or in one of them if it is a directory.
+func inPaths(name string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.Trim(path.Clean(filepath.ToSlash(p)), "/")
+		if p == "." || p == "" || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneContext clones the remote at url into the storage of the client with
+// the given options. If the remote rejects the credentials of a refreshable
+// provider, the clone is retried once with refreshed credentials.
+func (g *Client) cloneContext(ctx context.Context, url string, cloneOpts *extgogit.CloneOptions) (*extgogit.Repository, error) {
+	var repo *extgogit.Repository
+	err := g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		cloneOpts.Auth = authMethod
+		var err error
+		repo, err = extgogit.CloneContext(ctx, g.storer, g.worktreeFS, cloneOpts)
+		if isAuthError(err) && refreshableCredentials(g.authOpts) {
+			// The repository is initialized before the remote is contacted,
+			// which must be undone for the clone to be retried.
+			if err := uninitRepository(g.storer); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+	return repo, err
+}
+
+// uninitRepository removes the HEAD reference and the default remote that
+// a clone writes to the storer before it fetches any objects, after which
+// the storer no longer contains a repository.
+func uninitRepository(s storage.Storer) error {
+	if err := s.RemoveReference(plumbing.HEAD); err != nil {
+		return err
+	}
+	cfg, err := s.Config()
+	if err != nil {
+		return err
+	}
+	delete(cfg.Remotes, git.DefaultRemote)
+	return s.SetConfig(cfg)
+}
+
+func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.ReferenceName) (string, error) {
 	// ref: https://git-scm.com/docs/git-check-ref-format#_description; point no. 6
 	if strings.HasPrefix(ref.String(), "/") || strings.HasSuffix(ref.String(), "/") {
 		return "", fmt.Errorf("ref %s is invalid; Git refs cannot begin or end with a slash '/'", ref.String())
@@ -469,13 +667,19 @@ func (g *Client) getRemoteHEAD(ctx context.Context, url string, ref plumbing.Ref
 		URLs: []string{url},
 	}
 	remote := extgogit.NewRemote(memory.NewStorage(), remoteCfg)
-	listOpts := &extgogit.ListOptions{
-		Auth:          authMethod,
-		CABundle:      caBundle(g.authOpts),
-		PeelingOption: extgogit.AppendPeeled,
-		ProxyOptions:  g.proxy,
-	}
-	refs, err := remote.ListContext(ctx, listOpts)
+	var refs []*plumbing.Reference
+	err := g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		var err error
+		refs, err = remote.ListContext(ctx, &extgogit.ListOptions{
+			Auth:          authMethod,
+			CABundle:      caBundle(g.authOpts),
+			ClientCert:    clientCert(g.authOpts),
+			ClientKey:     clientKey(g.authOpts),
+			PeelingOption: extgogit.AppendPeeled,
+			ProxyOptions:  g.proxy,
+		})
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("unable to list remote for '%s': %w", url, err)
 	}
@@ -544,6 +748,14 @@ func buildTag(t *object.Tag, ref plumbing.ReferenceName) (*git.Tag, error) {
 		}, nil
 	}
 
+	// go-git does not recognise X.509 signatures of tags, which are
+	// therefore left as part of the message.
+	if i := strings.Index(t.Message, x509SignatureHeader); t.PGPSignature == "" && i >= 0 {
+		tt := *t
+		tt.Message, tt.PGPSignature = t.Message[:i], t.Message[i:]
+		t = &tt
+	}
+
 	encoded := &plumbing.MemoryObject{}
 	if err := t.EncodeWithoutSignature(encoded); err != nil {
 		return nil, fmt.Errorf("unable to encode tag '%s': %w", t.Name, err)
@@ -616,8 +828,9 @@ func goGitError(err error) error {
 	if err == nil {
 		return nil
 	}
-	switch strings.TrimSpace(err.Error()) {
-	case "unknown error: remote:":
+	msg := strings.TrimSpace(err.Error())
+	switch {
+	case msg == "unknown error: remote:":
 		// this unhelpful error arises because go-git takes the first
 		// line of the output on stderr, and for some git providers
 		// (GitLab, at least) the output has a blank line at the
@@ -625,6 +838,12 @@ func goGitError(err error) error {
 		// the actual error; but at least we know what was being
 		// attempted, and the likely cause.
 		return fmt.Errorf("push rejected; check git secret has write access")
+	case strings.Contains(msg, "object-format=sha256"):
+		// go-git's implementation of the wire protocol assumes SHA-1
+		// object IDs, even when built with the "sha256" tag, and fails to parse
+		// the reference advertisement of SHA-256 repositories with an obscure
+		// pkt-line error.
+		return fmt.Errorf("remote repository uses the SHA-256 object format, which is not supported over the wire by go-git: %w", err)
 	default:
 		return err
 	}
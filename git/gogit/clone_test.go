@@ -18,8 +18,10 @@ package gogit
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	iofs "io/fs"
 	"net"
 	"net/http"
@@ -673,6 +675,271 @@ func TestClone_cloneRefName(t *testing.T) {
 	}
 }
 
+func TestClone_SparseCheckout(t *testing.T) {
+	repo, repoPath, err := initRepo(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"apps/app.yaml", "apps/nested/app.yaml", "infra/infra.yaml", "appsettings.yaml", "README.md"} {
+		if _, err = commitFile(repo, f, f, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tag(repo, head.Hash(), true, "v1.0.0", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		strategy repository.CheckoutStrategy
+		dirs     []string
+		want     []string
+		wantNot  []string
+	}{
+		{
+			name:     "branch",
+			strategy: repository.CheckoutStrategy{Branch: "master"},
+			dirs:     []string{"apps"},
+			want:     []string{"apps/app.yaml", "apps/nested/app.yaml"},
+			wantNot:  []string{"infra", "appsettings.yaml", "README.md"},
+		},
+		{
+			name:     "tag",
+			strategy: repository.CheckoutStrategy{Tag: "v1.0.0"},
+			dirs:     []string{"apps/nested/", "infra"},
+			want:     []string{"apps/nested/app.yaml", "infra/infra.yaml"},
+			wantNot:  []string{"apps/app.yaml", "appsettings.yaml", "README.md"},
+		},
+		{
+			name:     "semver",
+			strategy: repository.CheckoutStrategy{SemVer: ">=1.0.0"},
+			dirs:     []string{"./infra"},
+			want:     []string{"infra/infra.yaml"},
+			wantNot:  []string{"apps", "appsettings.yaml", "README.md"},
+		},
+		{
+			name:     "commit",
+			strategy: repository.CheckoutStrategy{Commit: head.Hash().String()},
+			dirs:     []string{"apps"},
+			want:     []string{"apps/app.yaml", "apps/nested/app.yaml"},
+			wantNot:  []string{"infra", "appsettings.yaml", "README.md"},
+		},
+		{
+			name:     "refname",
+			strategy: repository.CheckoutStrategy{RefName: "refs/heads/master"},
+			dirs:     []string{"infra"},
+			want:     []string{"infra/infra.yaml"},
+			wantNot:  []string{"apps", "appsettings.yaml", "README.md"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tmpDir := t.TempDir()
+			ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			cc, err := ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{
+				CheckoutStrategy:          tt.strategy,
+				ShallowClone:              true,
+				SparseCheckoutDirectories: tt.dirs,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(head.Hash().String()))
+
+			for _, f := range tt.want {
+				g.Expect(filepath.Join(tmpDir, f)).To(BeARegularFile())
+				g.Expect(os.ReadFile(filepath.Join(tmpDir, f))).To(BeEquivalentTo(f))
+			}
+			for _, f := range tt.wantNot {
+				g.Expect(filepath.Join(tmpDir, f)).ToNot(BeAnExistingFile())
+			}
+
+			// The status is computed without writing the index.
+			idx, err := os.ReadFile(filepath.Join(tmpDir, ".git", "index"))
+			g.Expect(err).ToNot(HaveOccurred())
+			clean, err := ggc.IsClean()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(clean).To(BeTrue())
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, ".git", "index"))).To(Equal(idx))
+
+			// Committing in a sparse checkout must retain the files
+			// outside of the sparse checkout directories.
+			hash, err := ggc.Commit(git.Commit{
+				Author:  git.Signature{Name: "Test User", Email: "test@example.com"},
+				Message: "Sparse commit",
+			}, repository.WithFiles(map[string]io.Reader{
+				tt.want[0]: strings.NewReader("changed"),
+			}))
+			g.Expect(err).ToNot(HaveOccurred())
+
+			commit, err := ggc.repository.CommitObject(plumbing.NewHash(hash))
+			g.Expect(err).ToNot(HaveOccurred())
+			tree, err := commit.Tree()
+			g.Expect(err).ToNot(HaveOccurred())
+			var files []string
+			g.Expect(tree.Files().ForEach(func(f *object.File) error {
+				files = append(files, f.Name)
+				return nil
+			})).To(Succeed())
+			g.Expect(files).To(ConsistOf("apps/app.yaml", "apps/nested/app.yaml", "infra/infra.yaml", "appsettings.yaml", "README.md"))
+
+			f, err := tree.File(tt.want[0])
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(f.Contents()).To(Equal("changed"))
+		})
+	}
+}
+
+func TestClone_SparseCheckoutRecurseSubmodules(t *testing.T) {
+	g := NewWithT(t)
+
+	_, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cfg := repository.CloneConfig{
+		CheckoutStrategy:          repository.CheckoutStrategy{Branch: "master"},
+		RecurseSubmodules:         true,
+		SparseCheckoutDirectories: []string{"apps"},
+	}
+	wantErr := "sparse checkout directories cannot be combined with recursing submodules"
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, cfg)
+	g.Expect(err).To(MatchError(wantErr))
+
+	_, err = ggc.Fetch(context.TODO(), repoPath, cfg)
+	g.Expect(err).To(MatchError(wantErr))
+}
+
+func TestClone_IncludePaths(t *testing.T) {
+	serverRoot := t.TempDir()
+	repoPath := filepath.Join(serverRoot, "repo")
+	repo, _, err := initRepo(repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := commitFile(repo, "apps/app.yaml", "app", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = commitFile(repo, "infra/infra.yaml", "infra", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	head, err := commitFile(repo, "README.md", "readme", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow the last observed commit to be fetched by its hash, as is
+	// required for shallow clones.
+	if out, err := exec.Command("git", "--git-dir", repoPath, "config", "uploadpack.allowReachableSHA1InWant", "true").CombinedOutput(); err != nil {
+		t.Fatalf("unable to configure repository: %s: %s", err, out)
+	}
+	server := gittestserver.NewGitServer(serverRoot)
+	if err = server.StartHTTP(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.StopHTTP()
+	repoURL := server.HTTPAddress() + "/repo"
+
+	tests := []struct {
+		name               string
+		lastObservedCommit string
+		includePaths       []string
+		shallow            bool
+		wantConcrete       bool
+	}{
+		{
+			name:               "unrelated changes",
+			lastObservedCommit: "master@sha1:" + base.String(),
+			includePaths:       []string{"apps", "clusters"},
+			wantConcrete:       false,
+		},
+		{
+			name:               "unrelated changes in shallow clone",
+			lastObservedCommit: "master@sha1:" + base.String(),
+			includePaths:       []string{"apps"},
+			shallow:            true,
+			wantConcrete:       false,
+		},
+		{
+			name:               "relevant changes",
+			lastObservedCommit: "master@sha1:" + base.String(),
+			includePaths:       []string{"apps", "infra"},
+			wantConcrete:       true,
+		},
+		{
+			name:               "relevant file change in shallow clone",
+			lastObservedCommit: base.String(),
+			includePaths:       []string{"README.md"},
+			shallow:            true,
+			wantConcrete:       true,
+		},
+		{
+			name:               "unknown last observed commit",
+			lastObservedCommit: "master@sha1:a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+			includePaths:       []string{"apps"},
+			wantConcrete:       true,
+		},
+		{
+			name:               "without include paths",
+			lastObservedCommit: "master@sha1:" + base.String(),
+			wantConcrete:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			cc, err := ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{
+				CheckoutStrategy:   repository.CheckoutStrategy{Branch: "master"},
+				LastObservedCommit: tt.lastObservedCommit,
+				IncludePaths:       tt.includePaths,
+				ShallowClone:       tt.shallow,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(head.String()))
+			g.Expect(cc.Reference).To(Equal("refs/heads/master"))
+			g.Expect(git.IsConcreteCommit(*cc)).To(Equal(tt.wantConcrete))
+		})
+	}
+}
+
+func Test_inPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		dirs []string
+		want bool
+	}{
+		{name: "apps/app.yaml", dirs: []string{"apps"}, want: true},
+		{name: "apps/app.yaml", dirs: []string{"apps/"}, want: true},
+		{name: "apps/app.yaml", dirs: []string{"/apps"}, want: true},
+		{name: "apps/app.yaml", dirs: []string{"./apps"}, want: true},
+		{name: "apps/app.yaml", dirs: []string{"."}, want: true},
+		{name: "apps/nested/app.yaml", dirs: []string{"infra", "apps/nested"}, want: true},
+		{name: "appsettings.yaml", dirs: []string{"apps"}, want: false},
+		{name: "apps2/app.yaml", dirs: []string{"apps"}, want: false},
+		{name: "infra/infra.yaml", dirs: []string{"apps"}, want: false},
+		{name: "infra/infra.yaml", dirs: []string{"infra/infra.yaml"}, want: true},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(inPaths(tt.name, tt.dirs)).To(Equal(tt.want), "name %q, dirs %v", tt.name, tt.dirs)
+	}
+}
+
 func Test_cloneSubmodule(t *testing.T) {
 	g := NewWithT(t)
 
@@ -912,7 +1179,7 @@ func Test_ssh_KeyExchangeAlgos(t *testing.T) {
 			name:      "non-matching kex",
 			ClientKex: []string{"ecdh-sha2-nistp521"},
 			ServerKex: []string{"curve25519-sha256@libssh.org"},
-			wantErr:   "ssh: no common algorithm for key exchange; client offered: [ecdh-sha2-nistp521 ext-info-c], server offered: [curve25519-sha256@libssh.org]",
+			wantErr:   "ssh: no common algorithm for key exchange; client offered: [ecdh-sha2-nistp521 ext-info-c kex-strict-c-v00@openssh.com], server offered: [curve25519-sha256@libssh.org kex-strict-s-v00@openssh.com]",
 		},
 	}
 
@@ -1156,6 +1423,102 @@ func Test_ssh_HostKeyAlgos(t *testing.T) {
 	}
 }
 
+func TestClone_sshCertificate(t *testing.T) {
+	g := NewWithT(t)
+	timeout := 5 * time.Second
+
+	// Use the default algorithms, which may have been overridden by
+	// previous tests.
+	kexAlgos, hostKeyAlgos := git.KexAlgos, git.HostKeyAlgos
+	git.KexAlgos, git.HostKeyAlgos = nil, nil
+	defer func() {
+		git.KexAlgos, git.HostKeyAlgos = kexAlgos, hostKeyAlgos
+	}()
+
+	caKP, err := ssh.GenerateKeyPair(ssh.ED25519)
+	g.Expect(err).ToNot(HaveOccurred())
+	ca, err := cryptossh.ParsePrivateKey(caKP.PrivateKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	checker := &cryptossh.CertChecker{
+		IsUserAuthority: func(auth cryptossh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+	}
+
+	server := gittestserver.NewGitServer(t.TempDir()).WithSSHConfig(&cryptossh.ServerConfig{
+		PublicKeyCallback: checker.Authenticate,
+	})
+	server.KeyDir(filepath.Join(server.Root(), "keys"))
+	g.Expect(server.ListenSSH()).To(Succeed())
+	go func() {
+		server.StartSSH()
+	}()
+	defer server.StopSSH()
+
+	repoPath := "test.git"
+	g.Expect(server.InitRepo(testRepositoryPath, git.DefaultBranch, repoPath)).To(Succeed())
+	repoURL := server.SSHAddress() + "/" + repoPath
+
+	u, err := url.Parse(repoURL)
+	g.Expect(err).NotTo(HaveOccurred())
+	knownHosts, err := ssh.ScanHostKey(u.Host, timeout, nil, false)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	kp, err := ssh.GenerateKeyPair(ssh.ED25519)
+	g.Expect(err).ToNot(HaveOccurred())
+	pub, _, _, _, err := cryptossh.ParseAuthorizedKey(kp.PublicKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	cert := &cryptossh.Certificate{
+		Key:             pub,
+		CertType:        cryptossh.UserCert,
+		KeyId:           "flux",
+		ValidPrincipals: []string{git.DefaultPublicKeyAuthUser},
+		ValidBefore:     cryptossh.CertTimeInfinity,
+	}
+	g.Expect(cert.SignCert(rand.Reader, ca)).To(Succeed())
+
+	clone := func(data map[string][]byte) error {
+		authOpts, err := git.NewAuthOptions(*u, data)
+		g.Expect(err).ToNot(HaveOccurred())
+		ggc, err := NewClient(t.TempDir(), authOpts)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+		defer cancel()
+		_, err = ggc.Clone(ctx, repoURL, repository.CloneConfig{
+			CheckoutStrategy: repository.CheckoutStrategy{
+				Branch: git.DefaultBranch,
+			},
+		})
+		return err
+	}
+
+	// Without the certificate, the public key of the identity is rejected.
+	err = clone(map[string][]byte{
+		"identity":    kp.PrivateKey,
+		"known_hosts": knownHosts,
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to authenticate"))
+
+	g.Expect(clone(map[string][]byte{
+		"identity":          kp.PrivateKey,
+		"identity-cert.pub": cryptossh.MarshalAuthorizedKey(cert),
+		"known_hosts":       knownHosts,
+	})).To(Succeed())
+
+	// A certificate of another key can not be used with the identity.
+	other, err := ssh.GenerateKeyPair(ssh.ED25519)
+	g.Expect(err).ToNot(HaveOccurred())
+	err = clone(map[string][]byte{
+		"identity":          other.PrivateKey,
+		"identity-cert.pub": cryptossh.MarshalAuthorizedKey(cert),
+		"known_hosts":       knownHosts,
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to use SSH certificate with identity"))
+}
+
 func TestCloneAndPush_WithProxy(t *testing.T) {
 	g := NewWithT(t)
 
@@ -1241,7 +1604,7 @@ func Test_getRemoteHEAD(t *testing.T) {
 	ggc, err := NewClient("", nil)
 	g.Expect(err).ToNot(HaveOccurred())
 
-	head, err := ggc.getRemoteHEAD(context.TODO(), path, ref, nil)
+	head, err := ggc.getRemoteHEAD(context.TODO(), path, ref)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(head).To(Equal(fmt.Sprintf("refs/heads/%s@%s", git.DefaultBranch, git.Hash(cc.String()).Digest())))
 
@@ -1251,22 +1614,22 @@ func Test_getRemoteHEAD(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 
 	ref = plumbing.NewTagReferenceName("v0.1.0")
-	head, err = ggc.getRemoteHEAD(context.TODO(), path, ref, nil)
+	head, err = ggc.getRemoteHEAD(context.TODO(), path, ref)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(head).To(Equal(fmt.Sprintf("refs/tags/%s@%s", "v0.1.0", git.Hash(cc.String()).Digest())))
 
 	ref = plumbing.NewTagReferenceName("v0.1.0" + tagDereferenceSuffix)
-	head, err = ggc.getRemoteHEAD(context.TODO(), path, ref, nil)
+	head, err = ggc.getRemoteHEAD(context.TODO(), path, ref)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(head).To(Equal(fmt.Sprintf("refs/tags/%s@%s", "v0.1.0"+tagDereferenceSuffix, git.Hash(cc.String()).Digest())))
 
 	ref = plumbing.ReferenceName("/refs/heads/main")
-	_, err = ggc.getRemoteHEAD(context.TODO(), path, ref, nil)
+	_, err = ggc.getRemoteHEAD(context.TODO(), path, ref)
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(Equal(fmt.Sprintf("ref %s is invalid; Git refs cannot begin or end with a slash '/'", ref.String())))
 
 	ref = plumbing.ReferenceName("refs/heads/main/")
-	_, err = ggc.getRemoteHEAD(context.TODO(), path, ref, nil)
+	_, err = ggc.getRemoteHEAD(context.TODO(), path, ref)
 	g.Expect(err).To(HaveOccurred())
 	g.Expect(err.Error()).To(Equal(fmt.Sprintf("ref %s is invalid; Git refs cannot begin or end with a slash '/'", ref.String())))
 }
@@ -1326,6 +1689,29 @@ func Test_filterRefs(t *testing.T) {
 	}
 }
 
+func Test_buildTag(t *testing.T) {
+	g := NewWithT(t)
+
+	sig := x509SignatureHeader + "\nMIIDoQYJKoZIhvcNAQcCoIIDkjCCA44CAQExDTALBglghkgBZQMEAgEwCwYJKoZI\n-----END SIGNED MESSAGE-----\n"
+	tagObj := &object.Tag{
+		Hash:       plumbing.NewHash("9000be6daa3323cb7009075259bb7bd62498d32f"),
+		Name:       "v1.0.0",
+		Tagger:     *mockSignature(time.Now()),
+		Message:    "Release v1.0.0\n" + sig,
+		TargetType: plumbing.CommitObject,
+		Target:     plumbing.NewHash("84d9be20ca15d29bebc629e5b6f29dab78cc69ba"),
+	}
+
+	tt, err := buildTag(tagObj, plumbing.NewTagReferenceName("v1.0.0"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tt.Message).To(Equal("Release v1.0.0\n"))
+	g.Expect(tt.Signature).To(Equal(sig))
+	g.Expect(string(tt.Encoded)).To(HaveSuffix("\n\nRelease v1.0.0\n"))
+	g.Expect(git.IsSignedTag(*tt)).To(BeTrue())
+	// The original object is left untouched.
+	g.Expect(tagObj.Message).To(ContainSubstring(sig))
+}
+
 func TestClone_CredentialsOverHttp(t *testing.T) {
 	tests := []struct {
 		name                     string
@@ -1516,6 +1902,38 @@ func TestClone_CredentialsOverHttp(t *testing.T) {
 	}
 }
 
+// TestClone_SHA256ObjectFormatUnsupported asserts that cloning a SHA-256
+// repository fails with a clear error, as the wire protocol implementation
+// of go-git assumes SHA-1 object IDs regardless of the build tags.
+func TestClone_SHA256ObjectFormatUnsupported(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found in PATH")
+	}
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	server.ObjectFormat(git.HashTypeSHA256)
+
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	repoPath := "sha256.git"
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, repoPath)).To(Succeed())
+
+	ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = ggc.Clone(context.TODO(), server.HTTPAddress()+"/"+repoPath, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{
+			Branch: git.DefaultBranch,
+		},
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("remote repository uses the SHA-256 object format"))
+}
+
 func TestGoGitErrorReplace(t *testing.T) {
 	// this is what go-git uses as the error message is if the remote
 	// sends a blank first line
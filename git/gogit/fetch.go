@@ -0,0 +1,329 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// Fetch updates the existing repository at the path of the client to the
+// target of the configured CheckoutStrategy. Only the objects missing from
+// the local repository are fetched from the remote at url, after which the
+// target is checked out, and the submodules are updated to the recorded
+// commits if RecurseSubmodules is set. It returns a Commit object
+// describing the Git commit that the repository HEAD points to.
+// If no repository exists at the path, it falls back to Clone.
+// The worktree is hard reset to the target, which discards any local
+// modifications.
+// LastObservedCommit is not taken into account, as fetching an up-to-date
+// reference does not transfer any objects.
+func (g *Client) Fetch(ctx context.Context, url string, cfg repository.CloneConfig) (*git.Commit, error) {
+	if err := g.validateUrl(url); err != nil {
+		return nil, err
+	}
+	if err := validateSparseCheckout(cfg); err != nil {
+		return nil, err
+	}
+
+	repo, err := extgogit.Open(g.storer, g.worktreeFS)
+	if err != nil {
+		if errors.Is(err, extgogit.ErrRepositoryNotExists) {
+			return g.Clone(ctx, url, cfg)
+		}
+		return nil, fmt.Errorf("unable to open repository: %w", err)
+	}
+	if err = setRemoteURL(repo, url); err != nil {
+		return nil, err
+	}
+	if err = validateClientCert(g.authOpts); err != nil {
+		return nil, err
+	}
+
+	target, err := newFetchTarget(cfg.CheckoutStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var depth int
+	if cfg.ShallowClone {
+		depth = 1
+	}
+	fetch := func(refSpecs ...config.RefSpec) error {
+		err := g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+			return repo.FetchContext(ctx, &extgogit.FetchOptions{
+				RemoteName:   git.DefaultRemote,
+				RefSpecs:     refSpecs,
+				Depth:        depth,
+				Auth:         authMethod,
+				Progress:     nil,
+				Tags:         extgogit.NoTags,
+				Force:        true,
+				Prune:        target.prune,
+				CABundle:     caBundle(g.authOpts),
+				ClientCert:   clientCert(g.authOpts),
+				ClientKey:    clientKey(g.authOpts),
+				ProxyOptions: g.proxy,
+			})
+		})
+		if err != nil && err != extgogit.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	}
+	if err = fetch(target.refSpecs...); err != nil {
+		if err == transport.ErrEmptyRemoteRepository || err == transport.ErrRepositoryNotFound {
+			return nil, git.ErrRepositoryNotFound{
+				Message: fmt.Sprintf("unable to fetch: %s", err),
+				URL:     url,
+			}
+		}
+		return nil, fmt.Errorf("unable to fetch '%s': %w", url, goGitError(err))
+	}
+	// The commit may not be reachable from the branches of the remote, or
+	// not be part of the shallow history of the local repository.
+	if target.commit != "" {
+		if _, err = repo.CommitObject(plumbing.NewHash(target.commit)); err != nil {
+			if err = fetch(config.RefSpec(fmt.Sprintf("%s:%s", target.commit, fetchCommitRef))); err != nil {
+				return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", target.commit, goGitError(err))
+			}
+			if err = repo.Storer.RemoveReference(fetchCommitRef); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ref := target.ref
+	if target.semver != nil {
+		t, err := latestSemVerTag(repo, cfg.SemVer, target.semver)
+		if err != nil {
+			return nil, err
+		}
+		ref = plumbing.NewTagReferenceName(t)
+	}
+
+	var (
+		cc     *object.Commit
+		tagObj *object.Tag
+	)
+	switch {
+	case target.commit != "":
+		if cc, err = repo.CommitObject(plumbing.NewHash(target.commit)); err != nil {
+			return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", target.commit, err)
+		}
+	case target.trackingRef != "":
+		if cc, err = resolveCommit(repo, target.trackingRef.String()); err != nil {
+			return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", ref, err)
+		}
+	default:
+		tagRef, err := repo.Reference(ref, false)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find reference '%s': %w", ref, err)
+		}
+		if cc, err = resolveCommit(repo, ref.String()); err != nil {
+			return nil, fmt.Errorf("unable to resolve commit object for '%s': %w", ref, err)
+		}
+		if ref.IsTag() {
+			tagObj, err = repo.TagObject(tagRef.Hash())
+			if err != nil && err != plumbing.ErrObjectNotFound {
+				return nil, fmt.Errorf("unable to resolve tag object for tag '%s' with hash '%s': %w", ref.Short(), tagRef.Hash(), err)
+			}
+		}
+	}
+
+	if err = checkoutFetched(repo, cc, target.branch, cfg.SparseCheckoutDirectories); err != nil {
+		return nil, fmt.Errorf("unable to checkout '%s': %w", cc.Hash, err)
+	}
+
+	g.repository = repo
+	g.sparseCheckoutDirs = cfg.SparseCheckoutDirectories
+	if cfg.RecurseSubmodules {
+		if err = g.updateSubmodules(ctx, repo, url, g.authOpts, cfg, int(extgogit.DefaultSubmoduleRecursionDepth)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.LFS {
+		if err = g.checkoutLFSObjects(ctx, url, cfg.LFSMaxSize); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RefName != "" {
+		ref = plumbing.ReferenceName(cfg.RefName)
+	}
+	return buildCommitWithRef(cc, tagObj, ref)
+}
+
+// fetchCommitRef is the reference a commit is temporarily fetched into
+// when it is not reachable from the fetched branches.
+const fetchCommitRef = "refs/fetch/commit"
+
+// fetchTarget describes what to fetch for a CheckoutStrategy, and how to
+// resolve the commit to checkout afterwards.
+type fetchTarget struct {
+	// refSpecs are the refspecs to fetch.
+	refSpecs []config.RefSpec
+	// ref is the reference the commit is resolved from, and returned with.
+	ref plumbing.ReferenceName
+	// trackingRef is the remote-tracking reference the commit is resolved
+	// from, if ref is a branch.
+	trackingRef plumbing.ReferenceName
+	// branch is the local branch to checkout, if any.
+	branch plumbing.ReferenceName
+	// commit is the hash of the commit to checkout, if any.
+	commit string
+	// semver is the constraint to select the tag to checkout with, if any.
+	semver *semver.Constraints
+	// prune defines if the local references matching the refspecs that no
+	// longer exist on the remote should be removed.
+	prune bool
+}
+
+func newFetchTarget(strategy repository.CheckoutStrategy) (*fetchTarget, error) {
+	switch {
+	case strategy.Commit != "":
+		t := &fetchTarget{
+			commit:   strategy.Commit,
+			refSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", git.DefaultRemote))},
+		}
+		if strategy.Branch != "" {
+			t.ref = plumbing.NewBranchReferenceName(strategy.Branch)
+			t.refSpecs = []config.RefSpec{branchRefSpec(t.ref)}
+		}
+		return t, nil
+	case strategy.RefName != "":
+		ref := plumbing.ReferenceName(strings.TrimSuffix(strategy.RefName, tagDereferenceSuffix))
+		if strings.HasPrefix(ref.String(), "/") || strings.HasSuffix(ref.String(), "/") {
+			return nil, fmt.Errorf("ref %s is invalid; Git refs cannot begin or end with a slash '/'", ref.String())
+		}
+		if ref.IsBranch() {
+			return newBranchFetchTarget(ref), nil
+		}
+		return &fetchTarget{
+			ref:      ref,
+			refSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%[1]s", ref))},
+		}, nil
+	case strategy.Tag != "":
+		ref := plumbing.NewTagReferenceName(strategy.Tag)
+		return &fetchTarget{
+			ref:      ref,
+			refSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%[1]s", ref))},
+		}, nil
+	case strategy.SemVer != "":
+		verConstraint, err := semver.NewConstraint(strategy.SemVer)
+		if err != nil {
+			return nil, fmt.Errorf("semver parse error: %w", err)
+		}
+		return &fetchTarget{
+			semver:   verConstraint,
+			refSpecs: []config.RefSpec{"+refs/tags/*:refs/tags/*"},
+			prune:    true,
+		}, nil
+	default:
+		branch := strategy.Branch
+		if branch == "" {
+			branch = git.DefaultBranch
+		}
+		return newBranchFetchTarget(plumbing.NewBranchReferenceName(branch)), nil
+	}
+}
+
+func newBranchFetchTarget(ref plumbing.ReferenceName) *fetchTarget {
+	return &fetchTarget{
+		ref:         ref,
+		trackingRef: plumbing.NewRemoteReferenceName(git.DefaultRemote, ref.Short()),
+		branch:      ref,
+		refSpecs:    []config.RefSpec{branchRefSpec(ref)},
+	}
+}
+
+// branchRefSpec returns the refspec to fetch the given branch into its
+// remote-tracking reference.
+func branchRefSpec(ref plumbing.ReferenceName) config.RefSpec {
+	return config.RefSpec(fmt.Sprintf("+%s:%s", ref, plumbing.NewRemoteReferenceName(git.DefaultRemote, ref.Short())))
+}
+
+// resolveCommit resolves the commit the given revision points to, peeling
+// annotated tags.
+func resolveCommit(repo *extgogit.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(*hash)
+}
+
+// setRemoteURL configures the default remote of the repository to point
+// to the given url.
+func setRemoteURL(repo *extgogit.Repository, url string) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("unable to read repository config: %w", err)
+	}
+	remote, ok := cfg.Remotes[git.DefaultRemote]
+	if ok && len(remote.URLs) == 1 && remote.URLs[0] == url {
+		return nil
+	}
+	if !ok {
+		remote = &config.RemoteConfig{Name: git.DefaultRemote}
+		cfg.Remotes[git.DefaultRemote] = remote
+	}
+	remote.URLs = []string{url}
+	if err = repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("unable to update remote '%s': %w", git.DefaultRemote, err)
+	}
+	return nil
+}
+
+// checkoutFetched checks out the given commit. If branch is set, the local
+// branch is reset to the commit and HEAD is pointed to it, otherwise HEAD
+// is detached at the commit.
+func checkoutFetched(repo *extgogit.Repository, cc *object.Commit, branch plumbing.ReferenceName, sparseDirs []string) error {
+	head := plumbing.NewHashReference(plumbing.HEAD, cc.Hash)
+	if branch != "" {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(branch, cc.Hash)); err != nil {
+			return err
+		}
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, branch)
+	}
+	if err := repo.Storer.SetReference(head); err != nil {
+		return err
+	}
+
+	if len(sparseDirs) > 0 {
+		return sparseCheckout(repo, cc, sparseDirs)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Reset(&extgogit.ResetOptions{
+		Commit: cc.Hash,
+		Mode:   extgogit.HardReset,
+	})
+}
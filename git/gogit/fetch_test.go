@@ -0,0 +1,299 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy repository.CheckoutStrategy
+		// tagName is the tag created on each upstream commit, suffixed
+		// with the index of the commit.
+		tagName string
+		wantRef string
+	}{
+		{
+			name:     "branch",
+			strategy: repository.CheckoutStrategy{Branch: "master"},
+			wantRef:  "refs/heads/master",
+		},
+		{
+			name:     "refname",
+			strategy: repository.CheckoutStrategy{RefName: "refs/heads/master"},
+			wantRef:  "refs/heads/master",
+		},
+		{
+			name:     "tag",
+			strategy: repository.CheckoutStrategy{Tag: "v1.0.0"},
+			tagName:  "v1.0.",
+			wantRef:  "refs/tags/v1.0.0",
+		},
+		{
+			name:     "semver",
+			strategy: repository.CheckoutStrategy{SemVer: ">=1.0.0"},
+			tagName:  "v1.0.",
+			wantRef:  "refs/tags/v1.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			repo, repoPath, err := initRepo(t.TempDir())
+			g.Expect(err).ToNot(HaveOccurred())
+			first, err := commitFile(repo, "file", "first", time.Now())
+			g.Expect(err).ToNot(HaveOccurred())
+			if tt.tagName != "" {
+				_, err = tag(repo, first, true, tt.tagName+"0", time.Now())
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+
+			tmpDir := t.TempDir()
+			ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+			g.Expect(err).ToNot(HaveOccurred())
+			cfg := repository.CloneConfig{CheckoutStrategy: tt.strategy}
+
+			cc, err := ggc.Clone(context.TODO(), repoPath, cfg)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(first.String()))
+
+			second, err := commitFile(repo, "file", "second", time.Now())
+			g.Expect(err).ToNot(HaveOccurred())
+			want := second
+			if tt.tagName != "" {
+				_, err = tag(repo, second, true, tt.tagName+"1", time.Now())
+				g.Expect(err).ToNot(HaveOccurred())
+				if tt.strategy.Tag != "" {
+					// A fixed tag does not move with new commits.
+					want = first
+				}
+			}
+
+			// Fetch with a new client, to ensure the on-disk repository
+			// is reused.
+			ggc, err = NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+			g.Expect(err).ToNot(HaveOccurred())
+			cc, err = ggc.Fetch(context.TODO(), repoPath, cfg)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(want.String()))
+			g.Expect(cc.Reference).To(Equal(tt.wantRef))
+
+			wantContent := "second"
+			if want == first {
+				wantContent = "first"
+			}
+			g.Expect(os.ReadFile(filepath.Join(tmpDir, "file"))).To(BeEquivalentTo(wantContent))
+
+			head, err := ggc.Head()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(head).To(Equal(want.String()))
+
+			clean, err := ggc.IsClean()
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(clean).To(BeTrue())
+
+			// Fetching again without any upstream changes is a no-op.
+			cc, err = ggc.Fetch(context.TODO(), repoPath, cfg)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(cc.Hash.String()).To(Equal(want.String()))
+		})
+	}
+}
+
+func TestFetch_Commit(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	second, err := commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cc, err := ggc.Fetch(context.TODO(), repoPath, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Commit: second.String()},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(second.String()))
+	g.Expect(os.ReadFile(filepath.Join(tmpDir, "file"))).To(BeEquivalentTo("second"))
+
+	_, err = ggc.Fetch(context.TODO(), repoPath, repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{Commit: "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2"},
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to resolve commit object"))
+}
+
+func TestFetch_ShallowCommit(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	first, err := commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// A commit which is not reachable from any branch.
+	g.Expect(createBranch(repo, "feature")).To(Succeed())
+	detached, err := commitFile(repo, "file", "detached", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master))).To(Succeed())
+	g.Expect(repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature"))).To(Succeed())
+	repoCfg, err := repo.Config()
+	g.Expect(err).ToNot(HaveOccurred())
+	repoCfg.Raw.Section("uploadpack").SetOption("allowAnySHA1InWant", "true")
+	g.Expect(repo.SetConfig(repoCfg)).To(Succeed())
+
+	tmpDir := t.TempDir()
+	ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoPath, repository.CloneConfig{ShallowClone: true})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	for _, want := range []struct {
+		hash    plumbing.Hash
+		content string
+	}{
+		{hash: first, content: "first"},
+		{hash: detached, content: "detached"},
+	} {
+		cc, err := ggc.Fetch(context.TODO(), repoPath, repository.CloneConfig{
+			CheckoutStrategy: repository.CheckoutStrategy{Commit: want.hash.String()},
+			ShallowClone:     true,
+		})
+		g.Expect(err).ToNot(HaveOccurred(), want.content)
+		g.Expect(cc.Hash.String()).To(Equal(want.hash.String()))
+		g.Expect(os.ReadFile(filepath.Join(tmpDir, "file"))).To(BeEquivalentTo(want.content))
+	}
+
+	_, err = ggc.repository.Reference(fetchCommitRef, false)
+	g.Expect(err).To(Equal(plumbing.ErrReferenceNotFound))
+}
+
+func TestFetch_SemVerPrunesTags(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	first, err := commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, first, true, "v1.0.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, second, true, "v1.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	cfg := repository.CloneConfig{
+		CheckoutStrategy: repository.CheckoutStrategy{SemVer: ">=1.0.0"},
+	}
+	cc, err := ggc.Clone(context.TODO(), repoPath, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(second.String()))
+
+	// A tag removed from the remote is no longer selected.
+	g.Expect(repo.DeleteTag("v1.1.0")).To(Succeed())
+	cc, err = ggc.Fetch(context.TODO(), repoPath, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(first.String()))
+	g.Expect(cc.Reference).To(Equal("refs/tags/v1.0.0"))
+
+	_, err = ggc.repository.Reference(plumbing.NewTagReferenceName("v1.1.0"), false)
+	g.Expect(err).To(Equal(plumbing.ErrReferenceNotFound))
+}
+
+func TestFetch_WithoutRepository(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	hash, err := commitFile(repo, "file", "content", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tmpDir := t.TempDir()
+	ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cc, err := ggc.Fetch(context.TODO(), repoPath, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cc.Hash.String()).To(Equal(hash.String()))
+	g.Expect(filepath.Join(tmpDir, "file")).To(BeARegularFile())
+}
+
+func TestFetch_SparseCheckout(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, repoPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, f := range []string{"apps/app.yaml", "apps/stale.yaml", "infra/infra.yaml"} {
+		_, err = commitFile(repo, f, f, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	tmpDir := t.TempDir()
+	ggc, err := NewClient(tmpDir, &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	cfg := repository.CloneConfig{
+		CheckoutStrategy:          repository.CheckoutStrategy{Branch: "master"},
+		SparseCheckoutDirectories: []string{"apps"},
+	}
+	_, err = ggc.Clone(context.TODO(), repoPath, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Join(tmpDir, "apps/stale.yaml")).To(BeARegularFile())
+
+	wt, err := repo.Worktree()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = wt.Remove("apps/stale.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "apps/new.yaml", "apps/new.yaml", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = ggc.Fetch(context.TODO(), repoPath, cfg)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(filepath.Join(tmpDir, "apps/app.yaml")).To(BeARegularFile())
+	g.Expect(filepath.Join(tmpDir, "apps/new.yaml")).To(BeARegularFile())
+	g.Expect(filepath.Join(tmpDir, "apps/stale.yaml")).ToNot(BeAnExistingFile())
+	g.Expect(filepath.Join(tmpDir, "infra")).ToNot(BeAnExistingFile())
+
+	clean, err := ggc.IsClean()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(clean).To(BeTrue())
+}
@@ -0,0 +1,502 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+const (
+	// lfsPointerVersion is the first line of a Git LFS pointer file.
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+	// lfsPointerMaxSize is the size in bytes a Git LFS pointer file is
+	// smaller than.
+	lfsPointerMaxSize = 1024
+	// lfsMediaType is the media type of the Git LFS batch API.
+	lfsMediaType = "application/vnd.git-lfs+json"
+	// lfsBatchSize is the maximum number of objects requested per batch.
+	lfsBatchSize = 100
+)
+
+// lfsPointer is a parsed Git LFS pointer file, as described in:
+// https://github.com/git-lfs/git-lfs/blob/main/docs/spec.md
+type lfsPointer struct {
+	Oid  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// parseLFSPointer parses the given content as a Git LFS pointer file. It
+// returns false if the content is not a valid pointer.
+func parseLFSPointer(content []byte) (*lfsPointer, bool) {
+	if len(content) >= lfsPointerMaxSize {
+		return nil, false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) < 3 || lines[0] != lfsPointerVersion {
+		return nil, false
+	}
+
+	p := &lfsPointer{Size: -1}
+	for _, l := range lines[1:] {
+		key, value, ok := strings.Cut(l, " ")
+		if !ok {
+			return nil, false
+		}
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, "sha256:")
+			if !ok || len(oid) != sha256.Size*2 {
+				return nil, false
+			}
+			if _, err := hex.DecodeString(oid); err != nil {
+				return nil, false
+			}
+			p.Oid = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, false
+			}
+			p.Size = size
+		}
+	}
+	if p.Oid == "" || p.Size < 0 {
+		return nil, false
+	}
+	return p, true
+}
+
+// readLFSPointer reads the blob with the given hash as a Git LFS pointer
+// file. It returns nil if the blob is not a pointer.
+func readLFSPointer(repo *extgogit.Repository, h plumbing.Hash) (*lfsPointer, error) {
+	obj, err := repo.Storer.EncodedObject(plumbing.BlobObject, h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Size() >= lfsPointerMaxSize {
+		return nil, nil
+	}
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := parseLFSPointer(content)
+	return p, nil
+}
+
+// lfsPointers returns the Git LFS pointers of the files checked out in the
+// worktree of the repository, mapped to their paths.
+func lfsPointers(repo *extgogit.Repository) (map[string]*lfsPointer, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	pointers := make(map[string]*lfsPointer)
+	for _, e := range idx.Entries {
+		if e.SkipWorktree || (e.Mode != filemode.Regular && e.Mode != filemode.Executable) {
+			continue
+		}
+		p, err := readLFSPointer(repo, e.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read '%s': %w", e.Name, err)
+		}
+		if p != nil {
+			pointers[e.Name] = p
+		}
+	}
+	return pointers, nil
+}
+
+// isLFSObject reports whether the file in the worktree is the Git LFS object
+// pointed to by the index entry. Such a file is considered unmodified, as
+// the pointer is its clean representation.
+func isLFSObject(repo *extgogit.Repository, fs billy.Filesystem, e *index.Entry) bool {
+	p, err := readLFSPointer(repo, e.Hash)
+	if err != nil || p == nil {
+		return false
+	}
+	f, err := fs.Open(e.Name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	return err == nil && n == p.Size && hex.EncodeToString(h.Sum(nil)) == p.Oid
+}
+
+// lfsBatchRequest is a request to the Git LFS batch API.
+type lfsBatchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers,omitempty"`
+	Objects   []*lfsPointer `json:"objects"`
+}
+
+// lfsBatchResponse is a response of the Git LFS batch API.
+type lfsBatchResponse struct {
+	Transfer string      `json:"transfer,omitempty"`
+	Objects  []lfsObject `json:"objects"`
+	Message  string      `json:"message,omitempty"`
+}
+
+// lfsObject is an object in a lfsBatchResponse.
+type lfsObject struct {
+	lfsPointer
+	Actions struct {
+		Download *lfsAction `json:"download,omitempty"`
+	} `json:"actions,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// lfsAction describes how to transfer an object.
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+// checkoutLFSObjects replaces the Git LFS pointer files in the worktree with
+// the objects they point to, downloaded from the LFS server of the remote
+// at the given url. If maxSize is greater than zero, objects exceeding it
+// result in an error.
+func (g *Client) checkoutLFSObjects(ctx context.Context, remote string, maxSize int64) error {
+	pointers, err := lfsPointers(g.repository)
+	if err != nil {
+		return fmt.Errorf("unable to find Git LFS pointers: %w", err)
+	}
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	endpoint, err := lfsEndpoint(remote)
+	if err != nil {
+		return err
+	}
+	client, err := g.lfsHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	// Multiple files can point to the same object, download it once.
+	paths := make(map[string][]string)
+	var objects []*lfsPointer
+	for name, p := range pointers {
+		if maxSize > 0 && p.Size > maxSize {
+			return fmt.Errorf("Git LFS object '%s' of '%s' is %d bytes, which exceeds the maximum of %d bytes", p.Oid, name, p.Size, maxSize)
+		}
+		if _, ok := paths[p.Oid]; !ok {
+			objects = append(objects, p)
+		}
+		paths[p.Oid] = append(paths[p.Oid], name)
+	}
+
+	for len(objects) > 0 {
+		batch := objects
+		if len(batch) > lfsBatchSize {
+			batch = batch[:lfsBatchSize]
+		}
+		objects = objects[len(batch):]
+
+		// The objects are downloaded with the credentials accepted by the
+		// batch API, which are refreshed if rejected.
+		var (
+			resp       *lfsBatchResponse
+			authMethod transport.AuthMethod
+		)
+		err = g.withAuth(ctx, remote, git.OperationRead, func(am transport.AuthMethod) error {
+			authMethod = am
+			resp, err = lfsBatch(ctx, client, endpoint, am, batch)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, obj := range resp.Objects {
+			if obj.Error != nil {
+				return fmt.Errorf("unable to download Git LFS object '%s': %s (%d)", obj.Oid, obj.Error.Message, obj.Error.Code)
+			}
+			names, ok := paths[obj.Oid]
+			if !ok {
+				continue
+			}
+			if obj.Actions.Download == nil {
+				return fmt.Errorf("unable to download Git LFS object '%s': no download action", obj.Oid)
+			}
+			if err = g.downloadLFSObject(ctx, client, endpoint, authMethod, obj.Actions.Download, pointers[names[0]], names); err != nil {
+				return fmt.Errorf("unable to download Git LFS object '%s': %w", obj.Oid, err)
+			}
+			delete(paths, obj.Oid)
+		}
+	}
+	if len(paths) > 0 {
+		return fmt.Errorf("unable to download %d Git LFS object(s): missing from batch response", len(paths))
+	}
+	return nil
+}
+
+// lfsBatch requests the download actions for the given objects from the
+// Git LFS batch API at the endpoint, authenticating with the given auth
+// method. Rejected credentials result in an error wrapping
+// transport.ErrAuthenticationRequired or transport.ErrAuthorizationFailed.
+func lfsBatch(ctx context.Context, client *http.Client, endpoint string, authMethod transport.AuthMethod, objects []*lfsPointer) (*lfsBatchResponse, error) {
+	body, err := json.Marshal(&lfsBatchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   objects,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", lfsMediaType)
+	req.Header.Set("Content-Type", lfsMediaType)
+	setLFSAuth(req, authMethod)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request Git LFS batch: %w", err)
+	}
+	defer res.Body.Close()
+
+	var resp lfsBatchResponse
+	if res.StatusCode != http.StatusOK {
+		_ = json.NewDecoder(res.Body).Decode(&resp)
+		err = fmt.Errorf("unexpected status code %d: %s", res.StatusCode, resp.Message)
+		switch res.StatusCode {
+		case http.StatusUnauthorized:
+			err = fmt.Errorf("%w: %s", transport.ErrAuthenticationRequired, err)
+		case http.StatusForbidden:
+			err = fmt.Errorf("%w: %s", transport.ErrAuthorizationFailed, err)
+		}
+		return nil, fmt.Errorf("unable to request Git LFS batch: %w", err)
+	}
+	if err = json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("unable to decode Git LFS batch response: %w", err)
+	}
+	if resp.Transfer != "" && resp.Transfer != "basic" {
+		return nil, fmt.Errorf("unsupported Git LFS transfer adapter '%s'", resp.Transfer)
+	}
+	return &resp, nil
+}
+
+// downloadLFSObject downloads the object using the given action, and writes
+// it to the files at the given paths. The content is verified against the
+// pointer, on failure the pointer file is restored.
+func (g *Client) downloadLFSObject(ctx context.Context, client *http.Client, endpoint string, authMethod transport.AuthMethod, action *lfsAction, p *lfsPointer, names []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.Href, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range action.Header {
+		req.Header.Set(k, v)
+	}
+	// Only send the credentials to the LFS server itself, and not to e.g.
+	// a storage service the download is delegated to.
+	if req.Header.Get("Authorization") == "" && sameHost(endpoint, action.Href) {
+		setLFSAuth(req, authMethod)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	if err = writeLFSObject(g.worktreeFS, names[0], res.Body, p); err != nil {
+		return err
+	}
+	for _, name := range names[1:] {
+		if err = copyFile(g.worktreeFS, names[0], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLFSObject writes the content of the object to the file with the
+// given name, while verifying its size and checksum.
+func writeLFSObject(fs billy.Filesystem, name string, r io.Reader, p *lfsPointer) (err error) {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		cErr := f.Close()
+		if err == nil {
+			err = cErr
+		}
+		if err != nil {
+			_ = restoreLFSPointer(fs, name, p)
+		}
+	}()
+
+	h := sha256.New()
+	// Read one byte more than expected to detect a size mismatch.
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, p.Size+1))
+	if err != nil {
+		return err
+	}
+	if n != p.Size {
+		return fmt.Errorf("expected %d bytes, got %d", p.Size, n)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != p.Oid {
+		return fmt.Errorf("checksum mismatch, got '%s'", sum)
+	}
+	return nil
+}
+
+// restoreLFSPointer writes the pointer to the file with the given name.
+func restoreLFSPointer(fs billy.Filesystem, name string, p *lfsPointer) error {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s\noid sha256:%s\nsize %d\n", lfsPointerVersion, p.Oid, p.Size)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// copyFile copies the content of the file src to the existing file dst.
+func copyFile(fs billy.Filesystem, src, dst string) error {
+	from, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+	to, err := fs.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(to, from); err != nil {
+		_ = to.Close()
+		return err
+	}
+	return to.Close()
+}
+
+// setLFSAuth sets the credentials of the auth method on the request, if it
+// is an HTTP auth method.
+func setLFSAuth(req *http.Request, authMethod transport.AuthMethod) {
+	if am, ok := authMethod.(githttp.AuthMethod); ok {
+		am.SetAuth(req)
+	}
+}
+
+// lfsHTTPClient returns an HTTP client configured with the CA bundle,
+// client certificate and proxy of the client.
+func (g *Client) lfsHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if g.authOpts != nil && len(g.authOpts.CAFile) > 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(g.authOpts.CAFile) {
+			return nil, errors.New("unable to append CA bundle to certificate pool")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+	if g.authOpts != nil && g.authOpts.Transport == git.HTTPS && len(g.authOpts.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair(g.authOpts.ClientCert, g.authOpts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if g.proxy.URL != "" {
+		proxyURL, err := g.proxy.FullURL()
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// lfsEndpoint returns the Git LFS server endpoint for the remote with the
+// given URL, following the conventions of the Git LFS client.
+func lfsEndpoint(remote string) (string, error) {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse URL '%s': %w", remote, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("Git LFS is only supported for HTTP(S) remotes, got scheme '%s'", u.Scheme)
+	}
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	u.Path += "/info/lfs"
+	return u.String(), nil
+}
+
+// sameHost reports whether both URLs have the same scheme and host.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
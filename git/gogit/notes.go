@@ -0,0 +1,291 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// Note returns the note of the commit the given revision resolves to,
+// from the local notes reference. Notes are not fetched by a clone, but
+// can be fetched using FetchNotes. If the commit has no note,
+// git.ErrNoteNotFound is returned.
+func (g *Client) Note(rev string, opts ...repository.NoteOption) (string, error) {
+	if g.repository == nil {
+		return "", git.ErrNoGitRepository
+	}
+	o := buildNoteOptions(opts)
+	ref, err := notesRefName(o.Ref)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := resolveCommit(g.repository, rev)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+	}
+	notes, err := g.notesCommit(ref)
+	if err != nil {
+		return "", err
+	}
+	if notes == nil {
+		return "", git.ErrNoteNotFound
+	}
+	tree, err := notes.Tree()
+	if err != nil {
+		return "", fmt.Errorf("unable to get tree of notes '%s': %w", ref, err)
+	}
+	_, note, err := findNote(tree, commit.Hash)
+	if err != nil {
+		return "", fmt.Errorf("unable to read note of commit '%s': %w", commit.Hash, err)
+	}
+	if note == nil {
+		return "", git.ErrNoteNotFound
+	}
+	return *note, nil
+}
+
+// AddNote adds the note to the commit the given revision resolves to, and
+// returns the hash of the commit of the notes reference recording it. An
+// existing note of the commit is replaced, unless WithNoteAppend is set.
+// The author defaults to the current time if it has no time.
+//
+// The notes reference is updated locally, and can be pushed using the
+// NotesRefs of the repository.PushConfig.
+func (g *Client) AddNote(rev, note string, author git.Signature, opts ...repository.NoteOption) (string, error) {
+	if g.repository == nil {
+		return "", git.ErrNoGitRepository
+	}
+	o := buildNoteOptions(opts)
+	ref, err := notesRefName(o.Ref)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(note) == "" {
+		return "", errors.New("unable to add empty note")
+	}
+
+	commit, err := resolveCommit(g.repository, rev)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve revision '%s': %w", rev, err)
+	}
+	parent, err := g.notesCommit(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var base *object.Tree
+	var parents []plumbing.Hash
+	notePath := commit.Hash.String()
+	note = strings.TrimRight(note, "\n") + "\n"
+	if parent != nil {
+		parents = append(parents, parent.Hash)
+		if base, err = parent.Tree(); err != nil {
+			return "", fmt.Errorf("unable to get tree of notes '%s': %w", ref, err)
+		}
+		// Update the note at its existing path, which may be in a fanout
+		// directory.
+		p, existing, err := findNote(base, commit.Hash)
+		if err != nil {
+			return "", fmt.Errorf("unable to read note of commit '%s': %w", commit.Hash, err)
+		}
+		if existing != nil {
+			notePath = p
+			if o.Append {
+				note = strings.TrimRight(*existing, "\n") + "\n\n" + note
+			}
+		}
+	}
+
+	s := g.repository.Storer
+	blob := s.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return "", err
+	}
+	if _, err = w.Write([]byte(note)); err != nil {
+		w.Close()
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	blobHash, err := s.SetEncodedObject(blob)
+	if err != nil {
+		return "", err
+	}
+	treeHash, err := writeTree(s, base, map[string]*object.TreeEntry{
+		notePath: {Mode: filemode.Regular, Hash: blobHash},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to write tree of notes '%s': %w", ref, err)
+	}
+
+	sig := buildObjectSignature(author, time.Now())
+	notesCommit := &object.Commit{
+		Author:       *sig,
+		Committer:    *sig,
+		Message:      fmt.Sprintf("Notes added for commit %s\n", commit.Hash),
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+	obj := s.NewEncodedObject()
+	if err = notesCommit.Encode(obj); err != nil {
+		return "", err
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+	if err = s.SetReference(plumbing.NewHashReference(ref, hash)); err != nil {
+		return "", fmt.Errorf("unable to update notes '%s': %w", ref, err)
+	}
+	return hash.String(), nil
+}
+
+// FetchNotes fetches the notes reference from the default remote,
+// replacing the local notes reference. If the remote does not have the
+// notes reference, the local notes reference is left as-is.
+func (g *Client) FetchNotes(ctx context.Context, opts ...repository.NoteOption) error {
+	if g.repository == nil {
+		return git.ErrNoGitRepository
+	}
+	o := buildNoteOptions(opts)
+	ref, err := notesRefName(o.Ref)
+	if err != nil {
+		return err
+	}
+	if err = validateClientCert(g.authOpts); err != nil {
+		return err
+	}
+
+	remote, err := g.repository.Remote(extgogit.DefaultRemoteName)
+	if err != nil {
+		return err
+	}
+	url := remote.Config().URLs[0]
+	err = g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		return g.repository.FetchContext(ctx, &extgogit.FetchOptions{
+			RemoteName:   git.DefaultRemote,
+			RefSpecs:     []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%[1]s", ref))},
+			Auth:         authMethod,
+			Tags:         extgogit.NoTags,
+			CABundle:     caBundle(g.authOpts),
+			ClientCert:   clientCert(g.authOpts),
+			ClientKey:    clientKey(g.authOpts),
+			ProxyOptions: g.proxy,
+		})
+	})
+	if err != nil && err != extgogit.NoErrAlreadyUpToDate && !errors.Is(err, extgogit.NoMatchingRefSpecError{}) {
+		return fmt.Errorf("unable to fetch notes '%s': %w", ref, goGitError(err))
+	}
+	return nil
+}
+
+// notesCommit returns the commit the notes reference points to, or nil if
+// the reference does not exist.
+func (g *Client) notesCommit(ref plumbing.ReferenceName) (*object.Commit, error) {
+	r, err := g.repository.Reference(ref, true)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to resolve notes '%s': %w", ref, err)
+	}
+	c, err := g.repository.CommitObject(r.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve commit of notes '%s': %w", ref, err)
+	}
+	return c, nil
+}
+
+// findNote returns the path and contents of the note of the given hash in
+// the tree of a notes reference, or a nil note if there is none. Git may
+// store notes in fanout directories, named after the leading characters of
+// the hash, for example "ab/cdef...".
+func findNote(tree *object.Tree, hash plumbing.Hash) (string, *string, error) {
+	name := hash.String()
+	var dir []string
+	for {
+		var fanout string
+		for _, e := range tree.Entries {
+			if e.Name == name && e.Mode.IsFile() {
+				f, err := tree.TreeEntryFile(&e)
+				if err != nil {
+					return "", nil, err
+				}
+				note, err := f.Contents()
+				if err != nil {
+					return "", nil, err
+				}
+				return path.Join(append(dir, name)...), &note, nil
+			}
+			if e.Mode == filemode.Dir && len(e.Name) == 2 && len(name) > 2 && strings.HasPrefix(name, e.Name) {
+				fanout = e.Name
+			}
+		}
+		if fanout == "" {
+			return "", nil, nil
+		}
+		sub, err := tree.Tree(fanout)
+		if err != nil {
+			return "", nil, err
+		}
+		tree, dir, name = sub, append(dir, fanout), name[2:]
+	}
+}
+
+// notesRefName returns the full name of the given notes reference, which
+// defaults to git.DefaultNotesRef.
+func notesRefName(ref string) (plumbing.ReferenceName, error) {
+	switch {
+	case ref == "":
+		ref = git.DefaultNotesRef
+	case !strings.HasPrefix(ref, "refs/"):
+		ref = "refs/notes/" + ref
+	}
+	// ref: https://git-scm.com/docs/git-check-ref-format#_description
+	if !strings.HasPrefix(ref, "refs/notes/") || strings.HasSuffix(ref, "/") || strings.HasSuffix(ref, ".lock") ||
+		strings.Contains(ref, "..") || strings.Contains(ref, "//") || strings.ContainsAny(ref, " ~^:?*[\\") {
+		return "", fmt.Errorf("invalid notes reference '%s'", ref)
+	}
+	return plumbing.ReferenceName(ref), nil
+}
+
+func buildNoteOptions(opts []repository.NoteOption) *repository.NoteOptions {
+	o := &repository.NoteOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestClient_notes(t *testing.T) {
+	g := NewWithT(t)
+
+	server, _, err := setupGitServer(false)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	defer server.StopHTTP()
+	repoURL := server.HTTPAddress() + "/test.git"
+
+	author := git.Signature{Name: "Test User", Email: "test@example.com"}
+	clone := func() *Client {
+		ggc, err := NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP}, WithMemoryStorage())
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+		return ggc
+	}
+
+	ggc := clone()
+	head, err := ggc.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Note(head)
+	g.Expect(err).To(Equal(git.ErrNoteNotFound))
+
+	// Add, replace and append to a note.
+	_, err = ggc.AddNote("HEAD", "applied-by: cluster-a", author)
+	g.Expect(err).ToNot(HaveOccurred())
+	note, err := ggc.Note(head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("applied-by: cluster-a\n"))
+
+	_, err = ggc.AddNote(head, "applied-by: cluster-b\n\n", author)
+	g.Expect(err).ToNot(HaveOccurred())
+	notesHash, err := ggc.AddNote(head, "verified: true", author, repository.WithNoteAppend())
+	g.Expect(err).ToNot(HaveOccurred())
+	note, err = ggc.Note(git.DefaultBranch)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("applied-by: cluster-b\n\nverified: true\n"))
+
+	notesCommit, err := ggc.repository.CommitObject(plumbing.NewHash(notesHash))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(notesCommit.NumParents()).To(Equal(1))
+	g.Expect(notesCommit.Author.Name).To(Equal(author.Name))
+
+	// Notes under another reference are kept apart.
+	_, err = ggc.Note(head, repository.WithNotesRef("reconcile"))
+	g.Expect(err).To(Equal(git.ErrNoteNotFound))
+	_, err = ggc.AddNote(head, "revision: 1", author, repository.WithNotesRef("refs/notes/reconcile"))
+	g.Expect(err).ToNot(HaveOccurred())
+	note, err = ggc.Note(head, repository.WithNotesRef("reconcile"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("revision: 1\n"))
+
+	// Notes are pushed along with the branch, and fetched separately.
+	g.Expect(ggc.Push(context.TODO(), repository.PushConfig{
+		NotesRefs: []string{git.DefaultNotesRef, "reconcile"},
+	})).To(Succeed())
+
+	other := clone()
+	_, err = other.Note(head)
+	g.Expect(err).To(Equal(git.ErrNoteNotFound))
+	g.Expect(other.FetchNotes(context.TODO())).To(Succeed())
+	note, err = other.Note(head)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("applied-by: cluster-b\n\nverified: true\n"))
+	g.Expect(other.FetchNotes(context.TODO(), repository.WithNotesRef("reconcile"))).To(Succeed())
+	note, err = other.Note(head, repository.WithNotesRef("reconcile"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("revision: 1\n"))
+	g.Expect(other.FetchNotes(context.TODO(), repository.WithNotesRef("missing"))).To(Succeed())
+
+	// A notes reference rejected as a non-fast-forward update is not
+	// retried by rebasing the branch.
+	_, err = other.AddNote(head, "applied-by: cluster-c", author)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(other.Push(context.TODO(), repository.PushConfig{NotesRefs: []string{git.DefaultNotesRef}})).To(Succeed())
+	_, err = ggc.AddNote(head, "applied-by: cluster-d", author)
+	g.Expect(err).ToNot(HaveOccurred())
+	err = ggc.Push(context.TODO(), repository.PushConfig{
+		NotesRefs:     []string{git.DefaultNotesRef},
+		RebaseRetries: 1,
+	})
+	var rejected git.ErrPushRejected
+	g.Expect(errors.As(err, &rejected)).To(BeTrue())
+	g.Expect(rejected.Reason).To(Equal(git.PushRejectedNonFastForward))
+
+	// Errors.
+	_, err = ggc.AddNote(head, "\n", author)
+	g.Expect(err).To(MatchError("unable to add empty note"))
+	_, err = ggc.AddNote("invalid", "note", author)
+	g.Expect(err).To(MatchError(ContainSubstring("unable to resolve revision 'invalid'")))
+	_, err = ggc.Note(head, repository.WithNotesRef("refs/heads/main"))
+	g.Expect(err).To(MatchError("invalid notes reference 'refs/heads/main'"))
+	g.Expect(ggc.Push(context.TODO(), repository.PushConfig{NotesRefs: []string{"in valid"}})).
+		To(MatchError("invalid notes reference 'refs/notes/in valid'"))
+}
+
+func TestClient_Note_fanout(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	first, err := commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc.repository = repo
+
+	// Write the note of the first commit in fanout directories, as done by
+	// Git for large numbers of notes.
+	_, err = ggc.AddNote(first.String(), "first", git.Signature{Name: "Test User"})
+	g.Expect(err).ToNot(HaveOccurred())
+	notes, err := ggc.notesCommit(plumbing.ReferenceName(git.DefaultNotesRef))
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err := notes.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	entry, err := tree.FindEntry(first.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	h := first.String()
+	fanoutPath := h[:2] + "/" + h[2:4] + "/" + h[4:]
+	treeHash, err := writeTree(repo.Storer, tree, map[string]*object.TreeEntry{
+		h:          nil,
+		fanoutPath: {Mode: filemode.Regular, Hash: entry.Hash},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	commit := &object.Commit{
+		Author:       *mockSignature(time.Now()),
+		Committer:    *mockSignature(time.Now()),
+		Message:      "Fanout notes",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{notes.Hash},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	g.Expect(commit.Encode(obj)).To(Succeed())
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(repo.Storer.SetReference(plumbing.NewHashReference(git.DefaultNotesRef, hash))).To(Succeed())
+
+	note, err := ggc.Note(first.String())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(note).To(Equal("first\n"))
+	_, err = ggc.Note(second.String())
+	g.Expect(err).To(Equal(git.ErrNoteNotFound))
+
+	// The note is updated at its existing path.
+	notesHash, err := ggc.AddNote(first.String(), "updated", git.Signature{Name: "Test User"})
+	g.Expect(err).ToNot(HaveOccurred())
+	notes, err = repo.CommitObject(plumbing.NewHash(notesHash))
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err = notes.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tree.FindEntry(h)
+	g.Expect(err).To(HaveOccurred())
+	f, err := tree.File(fanoutPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(f.Contents()).To(Equal("updated\n"))
+}
+
+func Test_notesRefName(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "", want: "refs/notes/commits"},
+		{ref: "reconcile", want: "refs/notes/reconcile"},
+		{ref: "refs/notes/flux/verify", want: "refs/notes/flux/verify"},
+		{ref: "refs/heads/main", wantErr: true},
+		{ref: "refs/notes/", wantErr: true},
+		{ref: "refs/notes/a..b", wantErr: true},
+		{ref: "refs/notes/a:b", wantErr: true},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		got, err := notesRefName(tt.ref)
+		if tt.wantErr {
+			g.Expect(err).To(HaveOccurred(), "ref %q", tt.ref)
+			continue
+		}
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(got.String()).To(Equal(tt.want))
+	}
+}
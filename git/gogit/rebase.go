@@ -0,0 +1,250 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+// rebaseOnRemote fetches the given branch from the origin at url, and
+// re-applies the local commits of the branch that are not part of the remote
+// branch on top of it, after which the branch is checked out.
+// The commits are re-applied path by path: a path changed by a commit is
+// updated if the remote branch has the same version of the path as the
+// parent of the commit, and left as-is if the remote branch already has
+// the change. Any other path results in a git.ErrRebaseConflict.
+// Commits that become empty are dropped. Signed commits are signed again
+// with the signer, and result in a git.ErrRebaseSignedCommit if it is nil.
+func (g *Client) rebaseOnRemote(ctx context.Context, url string, branch plumbing.ReferenceName, signer *openpgp.Entity) error {
+	remoteRef := plumbing.NewRemoteReferenceName(git.DefaultRemote, branch.Short())
+	err := g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		return g.repository.FetchContext(ctx, &extgogit.FetchOptions{
+			RemoteName:   git.DefaultRemote,
+			RefSpecs:     []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branch, remoteRef))},
+			Auth:         authMethod,
+			Tags:         extgogit.NoTags,
+			CABundle:     caBundle(g.authOpts),
+			ClientCert:   clientCert(g.authOpts),
+			ClientKey:    clientKey(g.authOpts),
+			ProxyOptions: g.proxy,
+		})
+	})
+	if err != nil && err != extgogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("unable to fetch remote branch '%s': %w", branch.Short(), goGitError(err))
+	}
+
+	upstreamRef, err := g.repository.Reference(remoteRef, true)
+	if err != nil {
+		return fmt.Errorf("unable to resolve remote branch '%s': %w", branch.Short(), err)
+	}
+	upstream, err := g.repository.CommitObject(upstreamRef.Hash())
+	if err != nil {
+		return fmt.Errorf("unable to resolve commit of remote branch '%s': %w", branch.Short(), err)
+	}
+	head, err := g.repository.Head()
+	if err != nil {
+		return err
+	}
+
+	local, err := localCommits(g.repository, head.Hash(), upstream.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Refuse signed commits before re-applying any, as a commit must not
+	// lose its signature.
+	if signer == nil {
+		for _, c := range local {
+			if c.PGPSignature != "" {
+				return git.ErrRebaseSignedCommit{
+					Branch: branch.Short(),
+					Commit: c.Hash.String(),
+				}
+			}
+		}
+	}
+
+	newHead := upstream
+	for _, c := range local {
+		if newHead, err = reapplyCommit(g.repository, c, newHead, branch, signer); err != nil {
+			return err
+		}
+	}
+
+	if err = checkoutFetched(g.repository, newHead, branch, g.sparseCheckoutDirs); err != nil {
+		return fmt.Errorf("unable to checkout '%s': %w", newHead.Hash, err)
+	}
+	return nil
+}
+
+// localCommits returns the commits reachable from head that are not
+// reachable from upstream, ordered from oldest to newest. It returns an
+// error if the commits contain a merge, or if they do not share history
+// with upstream.
+func localCommits(repo *extgogit.Repository, head, upstream plumbing.Hash) ([]*object.Commit, error) {
+	exclude := make(map[plumbing.Hash]struct{})
+	if err := walkCommits(repo, upstream, nil, func(c *object.Commit) error {
+		exclude[c.Hash] = struct{}{}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to walk history of '%s': %w", upstream, err)
+	}
+
+	var commits []*object.Commit
+	for h := head; ; {
+		if _, ok := exclude[h]; ok {
+			break
+		}
+		c, err := repo.CommitObject(h)
+		if err != nil {
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				return nil, fmt.Errorf("unable to find common ancestor of '%s' and '%s'", head, upstream)
+			}
+			return nil, err
+		}
+		if c.NumParents() != 1 {
+			return nil, fmt.Errorf("unable to rebase commit '%s': only commits with a single parent can be rebased", c.Hash)
+		}
+		commits = append([]*object.Commit{c}, commits...)
+		h = c.ParentHashes[0]
+	}
+	return commits, nil
+}
+
+// reapplyCommit applies the changes the commit made to its parent on top
+// of the given parent, and returns the resulting commit. If the commit is
+// signed, the resulting commit is signed with the signer. If the commit does
+// not result in any changes, the parent is returned.
+func reapplyCommit(repo *extgogit.Repository, c, parent *object.Commit, branch plumbing.ReferenceName, signer *openpgp.Entity) (*object.Commit, error) {
+	origParent, err := c.Parent(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve parent of commit '%s': %w", c.Hash, err)
+	}
+	from, err := origParent.Tree()
+	if err != nil {
+		return nil, err
+	}
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	base, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("unable to diff commit '%s': %w", c.Hash, err)
+	}
+
+	edits := make(map[string]*object.TreeEntry)
+	var conflicts []string
+	for _, ch := range changes {
+		path := ch.To.Name
+		if path == "" {
+			path = ch.From.Name
+		}
+		current, err := findTreeEntry(base, path)
+		if errors.As(err, &notDirectoryError{}) {
+			// The remote branch replaced a parent directory with a file.
+			conflicts = append(conflicts, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case sameChangeEntry(current, ch.To):
+			// The remote branch already contains the change.
+		case sameChangeEntry(current, ch.From):
+			if ch.To.Name == "" {
+				edits[path] = nil
+			} else {
+				entry := ch.To.TreeEntry
+				edits[path] = &entry
+			}
+		default:
+			conflicts = append(conflicts, path)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, git.ErrRebaseConflict{
+			Branch: branch.Short(),
+			Commit: c.Hash.String(),
+			Paths:  conflicts,
+		}
+	}
+	if len(edits) == 0 {
+		return parent, nil
+	}
+
+	treeHash, err := writeTree(repo.Storer, base, edits)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write tree for commit '%s': %w", c.Hash, err)
+	}
+	committer := c.Committer
+	committer.When = time.Now()
+	commit := &object.Commit{
+		Author:       c.Author,
+		Committer:    committer,
+		Message:      c.Message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+	if c.PGPSignature != "" {
+		if signer == nil {
+			return nil, git.ErrRebaseSignedCommit{
+				Branch: branch.Short(),
+				Commit: c.Hash.String(),
+			}
+		}
+		if commit.PGPSignature, err = signCommit(commit, signer); err != nil {
+			return nil, fmt.Errorf("unable to sign commit '%s': %w", c.Hash, err)
+		}
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err = commit.Encode(obj); err != nil {
+		return nil, err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(hash)
+}
+
+// sameChangeEntry returns true if the tree entry matches the change entry,
+// or if both do not exist.
+func sameChangeEntry(e *object.TreeEntry, ce object.ChangeEntry) bool {
+	if ce.Name == "" {
+		return e == nil
+	}
+	return e != nil && e.Hash == ce.TreeEntry.Hash && e.Mode == ce.TreeEntry.Mode
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+func Test_reapplyCommit_fileReplacesDirectory(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	baseHash, err := commitFile(repo, "apps/app.yaml", "app", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	localHash, err := commitFile(repo, "apps/app.yaml", "changed", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The remote replaced the directory of the changed file with a file.
+	base, err := repo.CommitObject(baseHash)
+	g.Expect(err).ToNot(HaveOccurred())
+	baseTree, err := base.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	app, err := baseTree.FindEntry("apps/app.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	treeHash, err := writeTree(repo.Storer, baseTree, map[string]*object.TreeEntry{
+		"apps":          app,
+		"apps/app.yaml": nil,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	obj := repo.Storer.NewEncodedObject()
+	g.Expect((&object.Commit{
+		Author:       *mockSignature(time.Now()),
+		Committer:    *mockSignature(time.Now()),
+		Message:      "Replace apps with a file",
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{baseHash},
+	}).Encode(obj)).To(Succeed())
+	remoteHash, err := repo.Storer.SetEncodedObject(obj)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	local, err := repo.CommitObject(localHash)
+	g.Expect(err).ToNot(HaveOccurred())
+	remote, err := repo.CommitObject(remoteHash)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = reapplyCommit(repo, local, remote, plumbing.Master, nil)
+	var conflictErr git.ErrRebaseConflict
+	g.Expect(errors.As(err, &conflictErr)).To(BeTrue())
+	g.Expect(conflictErr.Commit).To(Equal(localHash.String()))
+	g.Expect(conflictErr.Paths).To(Equal([]string{"apps/app.yaml"}))
+}
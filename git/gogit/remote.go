@@ -0,0 +1,161 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/fluxcd/pkg/git"
+)
+
+// ListRefs lists the references of the remote repository at url, like
+// `git ls-remote`, without cloning it. The references are ordered by name,
+// and annotated tags are peeled to the commit they point to. If the
+// repository is empty, no references are returned.
+func (g *Client) ListRefs(ctx context.Context, url string) ([]git.RemoteRef, error) {
+	if err := g.validateUrl(url); err != nil {
+		return nil, err
+	}
+	err := validateClientCert(g.authOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := extgogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemote,
+		URLs: []string{url},
+	})
+	var refs []*plumbing.Reference
+	err = g.withAuth(ctx, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+		var err error
+		refs, err = remote.ListContext(ctx, &extgogit.ListOptions{
+			Auth:          authMethod,
+			CABundle:      caBundle(g.authOpts),
+			ClientCert:    clientCert(g.authOpts),
+			ClientKey:     clientKey(g.authOpts),
+			PeelingOption: extgogit.AppendPeeled,
+			ProxyOptions:  g.proxy,
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return nil, nil
+		}
+		if errors.Is(err, transport.ErrRepositoryNotFound) {
+			return nil, git.ErrRepositoryNotFound{
+				Message: fmt.Sprintf("unable to list remote: %s", err),
+				URL:     url,
+			}
+		}
+		return nil, fmt.Errorf("unable to list remote for '%s': %w", url, goGitError(err))
+	}
+	return buildRemoteRefs(refs), nil
+}
+
+// ResolveSemVer resolves the SemVer constraint to the tag of the remote
+// repository at url with the highest matching version, without cloning it.
+// It returns a non-concrete commit with the hash of the commit the tag
+// points to, and the tag as reference.
+//
+// Versions are ordered as by a clone with a SemVer checkout strategy,
+// except for versions which only differ in build metadata: a clone orders
+// these by the time of the commit they point to, which is not available
+// without fetching the commits. These are ordered by tag name instead.
+func (g *Client) ResolveSemVer(ctx context.Context, url, semverTag string) (*git.Commit, error) {
+	verConstraint, err := semver.NewConstraint(semverTag)
+	if err != nil {
+		return nil, fmt.Errorf("semver parse error: %w", err)
+	}
+	refs, err := g.ListRefs(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	tagRefs := make(map[string]git.RemoteRef)
+	for _, ref := range refs {
+		if name, ok := strings.CutPrefix(ref.Name, "refs/tags/"); ok {
+			tags = append(tags, name)
+			tagRefs[name] = ref
+		}
+	}
+	t, err := latestSemVer(tags, nil, semverTag, verConstraint)
+	if err != nil {
+		return nil, err
+	}
+	return &git.Commit{
+		Hash:      tagRefs[t].CommitHash(),
+		Reference: tagRefs[t].Name,
+	}, nil
+}
+
+// buildRemoteRefs returns the git.RemoteRef objects for the given
+// references as listed by go-git, ordered by name. The peeled references
+// of annotated tags are merged into the references of the tags, and
+// symbolic references are resolved to the hash of their target.
+func buildRemoteRefs(refs []*plumbing.Reference) []git.RemoteRef {
+	hashes := make(map[string]plumbing.Hash)
+	peeled := make(map[string]plumbing.Hash)
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		name := ref.Name().String()
+		if tag, ok := strings.CutSuffix(name, tagDereferenceSuffix); ok {
+			peeled[tag] = ref.Hash()
+			continue
+		}
+		hashes[name] = ref.Hash()
+	}
+
+	var result []git.RemoteRef
+	for _, ref := range refs {
+		name := ref.Name().String()
+		if strings.HasSuffix(name, tagDereferenceSuffix) {
+			continue
+		}
+		r := git.RemoteRef{Name: name}
+		hash := ref.Hash()
+		if ref.Type() == plumbing.SymbolicReference {
+			r.Target = ref.Target().String()
+			hash = hashes[r.Target]
+		}
+		if !hash.IsZero() {
+			r.Hash = git.Hash(hash.String())
+		}
+		if p, ok := peeled[name]; ok {
+			r.PeeledHash = git.Hash(p.String())
+		}
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
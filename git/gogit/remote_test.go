@@ -0,0 +1,168 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/gittestserver"
+)
+
+func TestClient_ListRefs(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, path, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	first, err := commitFile(repo, "file", "first", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = tag(repo, first, false, "v0.1.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := commitFile(repo, "file", "second", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	annotated, err := tag(repo, second, true, "v0.2.0", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ggc, err := NewClient(t.TempDir(), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	refs, err := ggc.ListRefs(context.TODO(), path)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(refs).To(Equal([]git.RemoteRef{
+		{
+			Name:   "HEAD",
+			Hash:   git.Hash(second.String()),
+			Target: "refs/heads/master",
+		},
+		{
+			Name: "refs/heads/master",
+			Hash: git.Hash(second.String()),
+		},
+		{
+			Name: "refs/tags/v0.1.0",
+			Hash: git.Hash(first.String()),
+		},
+		{
+			Name:       "refs/tags/v0.2.0",
+			Hash:       git.Hash(annotated.Hash().String()),
+			PeeledHash: git.Hash(second.String()),
+		},
+	}))
+	g.Expect(refs[3].CommitHash()).To(Equal(git.Hash(second.String())))
+
+	// An empty repository has no references.
+	_, emptyPath, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	refs, err = ggc.ListRefs(context.TODO(), emptyPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(refs).To(BeEmpty())
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	ggc, err = NewClient(t.TempDir(), &git.AuthOptions{Transport: git.HTTP})
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.ListRefs(context.TODO(), server.HTTPAddress()+"/missing.git")
+	var notFound git.ErrRepositoryNotFound
+	g.Expect(errors.As(err, &notFound)).To(BeTrue())
+}
+
+func TestClient_ResolveSemVer(t *testing.T) {
+	now := time.Now()
+
+	repo, path, err := initRepo(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := make(map[string]plumbing.Hash)
+	for i, tt := range []struct {
+		tag       string
+		annotated bool
+	}{
+		{tag: "v0.0.1"},
+		{tag: "v0.1.0+build-1", annotated: true},
+		{tag: "v0.1.0+build-2"},
+		{tag: "0.2.0", annotated: true},
+		{tag: "invalid"},
+	} {
+		ref, err := commitFile(repo, "tag", tt.tag, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tag(repo, ref, tt.annotated, tt.tag, now); err != nil {
+			t.Fatal(err)
+		}
+		refs[tt.tag] = ref
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		wantTag    string
+		wantErr    string
+	}{
+		{
+			name:       "Orders by SemVer",
+			constraint: ">0.1.0",
+			wantTag:    "0.2.0",
+		},
+		{
+			name:       "Orders equal versions by name",
+			constraint: "<0.2.0",
+			wantTag:    "v0.1.0+build-2",
+		},
+		{
+			name:       "Errors without match",
+			constraint: ">=1.0.0",
+			wantErr:    "no match found for semver: >=1.0.0",
+		},
+		{
+			name:       "Errors on invalid constraint",
+			constraint: "invalid",
+			wantErr:    "semver parse error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ggc, err := NewClient(t.TempDir(), nil)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			cc, err := ggc.ResolveSemVer(context.TODO(), path, tt.constraint)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(git.IsConcreteCommit(*cc)).To(BeFalse())
+			g.Expect(cc.Hash).To(Equal(git.Hash(refs[tt.wantTag].String())))
+			g.Expect(cc.Reference).To(Equal("refs/tags/" + tt.wantTag))
+		})
+	}
+}
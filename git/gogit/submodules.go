@@ -0,0 +1,286 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// submoduleGitlinkRef is the reference the commit recorded by the gitlink of
+// a submodule is temporarily fetched into.
+const submoduleGitlinkRef = "refs/fetch/gitlink"
+
+// updateSubmodules checks out the submodules of repo, cloned from url with
+// authOpts, at the commits recorded by their gitlinks. Nested submodules are
+// checked out up to the given depth.
+//
+// go-git fetches submodules with the auth options of the parent repository
+// only, and without its CA bundle and proxy settings. The submodules are
+// therefore fetched by the client itself, with the auth options configured
+// for their URL.
+func (g *Client) updateSubmodules(ctx context.Context, repo *extgogit.Repository, url string,
+	authOpts *git.AuthOptions, cfg repository.CloneConfig, depth int) error {
+	if depth <= 0 {
+		return nil
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	subs, err := w.Submodules()
+	if err != nil {
+		return fmt.Errorf("unable to list submodules: %w", err)
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("unable to read index: %w", err)
+	}
+	for _, sub := range subs {
+		if err := g.updateSubmodule(ctx, sub, idx, url, authOpts, cfg, depth); err != nil {
+			return fmt.Errorf("unable to update submodule '%s': %w", sub.Config().Name, err)
+		}
+	}
+	return nil
+}
+
+// updateSubmodule fetches the submodule and checks out the commit recorded
+// by its gitlink in idx, followed by its own submodules. parentURL and
+// parentAuthOpts are the URL and auth options of the parent repository.
+func (g *Client) updateSubmodule(ctx context.Context, sub *extgogit.Submodule, idx *index.Index, parentURL string,
+	parentAuthOpts *git.AuthOptions, cfg repository.CloneConfig, depth int) error {
+	url, err := submoduleURL(parentURL, sub.Config().URL)
+	if err != nil {
+		return err
+	}
+	if !submoduleURLAllowed(url, cfg.AllowedSubmoduleURLs) {
+		return fmt.Errorf("URL '%s' is not allowed", url)
+	}
+	authOpts := g.submoduleAuthOptions(url, parentURL, parentAuthOpts)
+	if err = g.validateUrlWithAuth(url, authOpts); err != nil {
+		return err
+	}
+	if err = validateClientCert(authOpts); err != nil {
+		return err
+	}
+
+	e, err := idx.Entry(sub.Config().Path)
+	if err != nil {
+		return fmt.Errorf("unable to find gitlink '%s': %w", sub.Config().Path, err)
+	}
+	hash := e.Hash
+
+	if err = sub.Init(); err != nil && !errors.Is(err, extgogit.ErrSubmoduleAlreadyInitialized) {
+		return err
+	}
+	subRepo, err := sub.Repository()
+	if err != nil {
+		return err
+	}
+	if err = setRemoteURL(subRepo, url); err != nil {
+		return err
+	}
+
+	var fetchDepth int
+	if cfg.ShallowClone {
+		fetchDepth = 1
+	}
+	fetch := func(refSpecs ...config.RefSpec) error {
+		err := g.withAuthOptions(ctx, authOpts, url, git.OperationRead, func(authMethod transport.AuthMethod) error {
+			return subRepo.FetchContext(ctx, &extgogit.FetchOptions{
+				RemoteName:   git.DefaultRemote,
+				RefSpecs:     refSpecs,
+				Depth:        fetchDepth,
+				Auth:         authMethod,
+				Tags:         extgogit.NoTags,
+				CABundle:     caBundle(authOpts),
+				ClientCert:   clientCert(authOpts),
+				ClientKey:    clientKey(authOpts),
+				ProxyOptions: g.proxy,
+			})
+		})
+		if err != nil && err != extgogit.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	}
+
+	if !cfg.PinSubmodules {
+		if err = fetch(); err != nil {
+			return fmt.Errorf("unable to fetch '%s': %w", url, goGitError(err))
+		}
+	}
+	// The recorded commit may not be reachable from the branches of the
+	// remote, or not be part of their shallow history.
+	if _, err = subRepo.CommitObject(hash); err != nil {
+		if err = fetch(config.RefSpec(fmt.Sprintf("%s:%s", hash, submoduleGitlinkRef))); err != nil {
+			return fmt.Errorf("unable to fetch commit '%s' from '%s': %w", hash, url, goGitError(err))
+		}
+		if err = subRepo.Storer.RemoveReference(submoduleGitlinkRef); err != nil {
+			return err
+		}
+	}
+
+	subWT, err := subRepo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err = subWT.Checkout(&extgogit.CheckoutOptions{Hash: hash, Force: true}); err != nil {
+		return fmt.Errorf("unable to checkout commit '%s': %w", hash, err)
+	}
+	if err = subRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, hash)); err != nil {
+		return err
+	}
+	return g.updateSubmodules(ctx, subRepo, url, authOpts, cfg, depth-1)
+}
+
+// submoduleURL returns the URL of a submodule with the given URL, as
+// configured in the .gitmodules file of the repository cloned from
+// parentURL. Relative URLs are resolved against parentURL.
+func submoduleURL(parentURL, u string) (string, error) {
+	if !strings.HasPrefix(u, "./") && !strings.HasPrefix(u, "../") {
+		return u, nil
+	}
+	ep, err := transport.NewEndpoint(parentURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve relative URL '%s': %w", u, err)
+	}
+	ep.Path = path.Join(ep.Path, u)
+	return ep.String(), nil
+}
+
+// submoduleAuthOptions returns the auth options for the submodule at url,
+// as configured with WithSubmoduleAuthOptions. If no key matches the URL,
+// the auth options of the parent repository at parentURL are returned if
+// the submodule is served by the same host and port, so that its
+// credentials are never sent to another host. Otherwise, nil is returned
+// and the submodule is fetched anonymously.
+func (g *Client) submoduleAuthOptions(url, parentURL string, parentOpts *git.AuthOptions) *git.AuthOptions {
+	var opts *git.AuthOptions
+	if sameEndpointHost(url, parentURL) {
+		opts = parentOpts
+	}
+	match := ""
+	for key, o := range g.submoduleAuthOpts {
+		if len(key) < len(match) || (len(key) == len(match) && key > match) {
+			continue
+		}
+		if matchesSubmoduleURL(url, key) {
+			opts, match = o, key
+		}
+	}
+	return opts
+}
+
+// sameEndpointHost returns true if both URLs, which may be SCP-like, have
+// the same host and port.
+func sameEndpointHost(u1, u2 string) bool {
+	ep1, err := transport.NewEndpoint(u1)
+	if err != nil {
+		return false
+	}
+	ep2, err := transport.NewEndpoint(u2)
+	if err != nil {
+		return false
+	}
+	return ep1.Host != "" && strings.EqualFold(ep1.Host, ep2.Host) && endpointPort(ep1) == endpointPort(ep2)
+}
+
+// submoduleURLAllowed returns true if url matches any of the allowed hosts
+// or URL prefixes, or if there are none.
+func submoduleURLAllowed(url string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if matchesSubmoduleURL(url, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSubmoduleURL returns true if url matches the given host, with an
+// optional port, or the given URL prefix. A URL prefix matches if the
+// scheme, host and port are equal, and the path of url equals or is below
+// the path of the prefix. Credentials in url are ignored, and a url with
+// ".." path segments never matches a URL prefix.
+func matchesSubmoduleURL(url, hostOrPrefix string) bool {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return false
+	}
+	if !strings.Contains(hostOrPrefix, "://") {
+		if ep.Host == "" {
+			return false
+		}
+		return strings.EqualFold(hostOrPrefix, ep.Host) ||
+			strings.EqualFold(hostOrPrefix, fmt.Sprintf("%s:%d", ep.Host, endpointPort(ep)))
+	}
+
+	prefix, err := transport.NewEndpoint(hostOrPrefix)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(ep.Protocol, prefix.Protocol) || !strings.EqualFold(ep.Host, prefix.Host) ||
+		endpointPort(ep) != endpointPort(prefix) {
+		return false
+	}
+	for _, segment := range strings.Split(ep.Path, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	// The path of an SCP-like URL is relative.
+	p := path.Clean("/" + ep.Path)
+	prefixPath := path.Clean("/" + prefix.Path)
+	return p == prefixPath || strings.HasPrefix(p, strings.TrimSuffix(prefixPath, "/")+"/")
+}
+
+// endpointPort returns the port of the endpoint, or the default port of its
+// protocol if not set.
+func endpointPort(ep *transport.Endpoint) int {
+	if ep.Port != 0 {
+		return ep.Port
+	}
+	switch strings.ToLower(ep.Protocol) {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	case "ssh":
+		return 22
+	case "git":
+		return 9418
+	}
+	return 0
+}
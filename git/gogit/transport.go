@@ -17,6 +17,9 @@ limitations under the License.
 package gogit
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 
 	"github.com/go-git/go-git/v5/plumbing/transport"
@@ -30,22 +33,42 @@ import (
 
 // transportAuth constructs the transport.AuthMethod for the git.Transport of
 // the given git.AuthOptions. It returns the result, or an error.
-func transportAuth(opts *git.AuthOptions, fallbackToDefaultKnownHosts bool) (transport.AuthMethod, error) {
+// For HTTP(S), the credentials are obtained from the git.CredentialsProvider
+// of the options for the given request. For HTTP, an error is returned for
+// any credentials unless credentialsOverHTTP is set.
+func transportAuth(ctx context.Context, opts *git.AuthOptions, req git.CredentialsRequest, fallbackToDefaultKnownHosts, credentialsOverHTTP bool) (transport.AuthMethod, error) {
 	if opts == nil {
 		return nil, nil
 	}
 	switch opts.Transport {
 	case git.HTTPS, git.HTTP:
+		if opts.ProviderOpts != nil && opts.ProviderOpts.Name != git.ProviderGitHub {
+			return nil, fmt.Errorf("unknown provider '%s'", opts.ProviderOpts.Name)
+		}
+		creds, err := opts.Provider().Credentials(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get credentials: %w", err)
+		}
+		if creds == nil {
+			return nil, nil
+		}
+		insecure := opts.Transport == git.HTTP && !credentialsOverHTTP
 		// Some providers (i.e. GitLab) will reject empty credentials for
 		// public repositories.
-		if opts.Username != "" || opts.Password != "" {
+		if creds.Username != "" || creds.Password != "" {
+			if insecure {
+				return nil, errors.New("basic auth cannot be sent over HTTP")
+			}
 			return &http.BasicAuth{
-				Username: opts.Username,
-				Password: opts.Password,
+				Username: creds.Username,
+				Password: creds.Password,
 			}, nil
-		} else if opts.BearerToken != "" {
+		} else if creds.BearerToken != "" {
+			if insecure {
+				return nil, errors.New("bearer token cannot be sent over HTTP")
+			}
 			return &http.TokenAuth{
-				Token: opts.BearerToken,
+				Token: creds.BearerToken,
 			}, nil
 		}
 		return nil, nil
@@ -69,6 +92,17 @@ func transportAuth(opts *git.AuthOptions, fallbackToDefaultKnownHosts bool) (tra
 		if err != nil {
 			return nil, err
 		}
+		if len(opts.IdentityCert) > 0 {
+			cert, err := git.ParseSSHUserCertificate(opts.IdentityCert)
+			if err != nil {
+				return nil, err
+			}
+			// The certificate signer presents the certificate instead of
+			// the public key, and signs with the private key of the identity.
+			if pk.Signer, err = gossh.NewCertSigner(cert, pk.Signer); err != nil {
+				return nil, fmt.Errorf("unable to use SSH certificate with identity: %w", err)
+			}
+		}
 
 		var callback gossh.HostKeyCallback
 		if len(opts.KnownHosts) > 0 {
@@ -90,6 +124,50 @@ func transportAuth(opts *git.AuthOptions, fallbackToDefaultKnownHosts bool) (tra
 	}
 }
 
+// withAuth calls fn with the transport.AuthMethod for the operation on the
+// remote at url. If the credentials are supplied by a refreshable provider
+// and rejected by the remote, fn is called once more with refreshed
+// credentials.
+func (g *Client) withAuth(ctx context.Context, url string, op git.Operation, fn func(transport.AuthMethod) error) error {
+	return g.withAuthOptions(ctx, g.authOpts, url, op, fn)
+}
+
+// withAuthOptions is like withAuth, but uses the given git.AuthOptions
+// instead of those of the client.
+func (g *Client) withAuthOptions(ctx context.Context, opts *git.AuthOptions, url string, op git.Operation,
+	fn func(transport.AuthMethod) error) error {
+	req := git.CredentialsRequest{URL: url, Operation: op}
+	authMethod, err := transportAuth(ctx, opts, req, g.useDefaultKnownHosts, g.credentialsOverHTTP)
+	if err != nil {
+		return fmt.Errorf("unable to construct auth method with options: %w", err)
+	}
+	if err = fn(authMethod); !isAuthError(err) || !refreshableCredentials(opts) {
+		return err
+	}
+
+	req.Refresh = true
+	if authMethod, err = transportAuth(ctx, opts, req, g.useDefaultKnownHosts, g.credentialsOverHTTP); err != nil {
+		return fmt.Errorf("unable to construct auth method with options: %w", err)
+	}
+	return fn(authMethod)
+}
+
+// isAuthError returns true if the error indicates that the remote rejected
+// the credentials.
+func isAuthError(err error) bool {
+	return errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed)
+}
+
+// refreshableCredentials returns true if the credentials of the given
+// git.AuthOptions are supplied by a provider other than the static one.
+func refreshableCredentials(opts *git.AuthOptions) bool {
+	if opts == nil || (opts.Transport != git.HTTP && opts.Transport != git.HTTPS) {
+		return false
+	}
+	_, static := opts.Provider().(git.StaticCredentialsProvider)
+	return !static
+}
+
 // caBundle returns the CA bundle from the given git.AuthOptions.
 func caBundle(opts *git.AuthOptions) []byte {
 	if opts == nil {
@@ -98,6 +176,38 @@ func caBundle(opts *git.AuthOptions) []byte {
 	return opts.CAFile
 }
 
+// clientCert returns the PEM encoded TLS client certificate from the given
+// git.AuthOptions, if the transport is HTTPS. go-git configures a dedicated
+// HTTP transport with the certificate for every operation it is passed to,
+// which ensures connections authenticated with it are never shared.
+func clientCert(opts *git.AuthOptions) []byte {
+	if opts == nil || opts.Transport != git.HTTPS {
+		return nil
+	}
+	return opts.ClientCert
+}
+
+// clientKey returns the PEM encoded private key of the TLS client
+// certificate from the given git.AuthOptions, if the transport is HTTPS.
+func clientKey(opts *git.AuthOptions) []byte {
+	if opts == nil || opts.Transport != git.HTTPS {
+		return nil
+	}
+	return opts.ClientKey
+}
+
+// validateClientCert returns an error if the TLS client certificate of the
+// given git.AuthOptions can not be loaded.
+func validateClientCert(opts *git.AuthOptions) error {
+	if len(clientCert(opts)) == 0 {
+		return nil
+	}
+	if _, err := tls.X509KeyPair(opts.ClientCert, opts.ClientKey); err != nil {
+		return fmt.Errorf("unable to load client certificate: %w", err)
+	}
+	return nil
+}
+
 // CustomPublicKeys is a wrapper around ssh.PublicKeys to help us
 // customize the ssh config. It implements ssh.AuthMethod.
 type CustomPublicKeys struct {
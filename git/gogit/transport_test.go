@@ -17,11 +17,29 @@ limitations under the License.
 package gogit
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -31,6 +49,9 @@ import (
 	"golang.org/x/crypto/ssh/agent"
 
 	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/github"
+	"github.com/fluxcd/pkg/git/repository"
+	"github.com/fluxcd/pkg/gittestserver"
 )
 
 const (
@@ -79,6 +100,7 @@ func Test_transportAuth(t *testing.T) {
 		wantFunc                    func(g *WithT, t transport.AuthMethod, opts *git.AuthOptions)
 		kexAlgos                    []string
 		fallbackToDefaultKnownHosts bool
+		credentialsOverHTTP         bool
 		wantErr                     error
 	}{
 		{
@@ -106,6 +128,7 @@ func Test_transportAuth(t *testing.T) {
 				Username:  "example",
 				Password:  "password",
 			},
+			credentialsOverHTTP: true,
 			wantFunc: func(g *WithT, t transport.AuthMethod, opts *git.AuthOptions) {
 				g.Expect(t).To(Equal(&http.BasicAuth{
 					Username: opts.Username,
@@ -113,18 +136,47 @@ func Test_transportAuth(t *testing.T) {
 				}))
 			},
 		},
+		{
+			name: "HTTP basic auth without credentials over HTTP",
+			opts: &git.AuthOptions{
+				Transport: git.HTTP,
+				Username:  "example",
+				Password:  "password",
+			},
+			wantErr: errors.New("basic auth cannot be sent over HTTP"),
+		},
+		{
+			name: "HTTP credentials provider without credentials over HTTP",
+			opts: &git.AuthOptions{
+				Transport: git.HTTP,
+				CredentialsProvider: &rotatingCredentialsProvider{
+					username: "example",
+					password: "password",
+				},
+			},
+			wantErr: errors.New("basic auth cannot be sent over HTTP"),
+		},
 		{
 			name: "HTTP bearer token",
 			opts: &git.AuthOptions{
 				Transport:   git.HTTP,
 				BearerToken: "http-token",
 			},
+			credentialsOverHTTP: true,
 			wantFunc: func(g *WithT, t transport.AuthMethod, opts *git.AuthOptions) {
 				g.Expect(t).To(Equal(&http.TokenAuth{
 					Token: opts.BearerToken,
 				}))
 			},
 		},
+		{
+			name: "HTTP bearer token without credentials over HTTP",
+			opts: &git.AuthOptions{
+				Transport:   git.HTTP,
+				BearerToken: "http-token",
+			},
+			wantErr: errors.New("bearer token cannot be sent over HTTP"),
+		},
 		{
 			name: "HTTPS basic auth",
 			opts: &git.AuthOptions{
@@ -272,7 +324,7 @@ func Test_transportAuth(t *testing.T) {
 				git.KexAlgos = tt.kexAlgos
 			}
 
-			got, err := transportAuth(tt.opts, tt.fallbackToDefaultKnownHosts)
+			got, err := transportAuth(context.TODO(), tt.opts, git.CredentialsRequest{}, tt.fallbackToDefaultKnownHosts, tt.credentialsOverHTTP)
 			if tt.wantErr != nil {
 				g.Expect(err).To(Equal(tt.wantErr))
 				g.Expect(got).To(BeNil())
@@ -336,10 +388,10 @@ func Test_defaultKnownHosts(t *testing.T) {
 	os.Setenv("SSH_AUTH_SOCK", listener.Addr().String())
 	defer os.Unsetenv("SSH_AUTH_SOCK")
 
-	auth, err := transportAuth(&git.AuthOptions{
+	auth, err := transportAuth(context.TODO(), &git.AuthOptions{
 		Transport: git.SSH,
 		Username:  "git",
-	}, true)
+	}, git.CredentialsRequest{}, true, false)
 	g.Expect(err).ToNot(HaveOccurred())
 
 	defaultAuth, ok := auth.(*DefaultAuth)
@@ -355,3 +407,328 @@ func Test_caBundle(t *testing.T) {
 	g.Expect(caBundle(&git.AuthOptions{CAFile: []byte("foo")})).To(BeEquivalentTo("foo"))
 	g.Expect(caBundle(nil)).To(BeNil())
 }
+
+func Test_clientCert(t *testing.T) {
+	g := NewWithT(t)
+
+	opts := &git.AuthOptions{Transport: git.HTTPS, ClientCert: []byte("cert"), ClientKey: []byte("key")}
+	g.Expect(clientCert(opts)).To(BeEquivalentTo("cert"))
+	g.Expect(clientKey(opts)).To(BeEquivalentTo("key"))
+	g.Expect(clientCert(nil)).To(BeNil())
+	g.Expect(clientKey(nil)).To(BeNil())
+
+	opts.Transport = git.HTTP
+	g.Expect(clientCert(opts)).To(BeNil())
+	g.Expect(clientKey(opts)).To(BeNil())
+}
+
+func TestClient_clientCert(t *testing.T) {
+	g := NewWithT(t)
+
+	ca, cert, key := generateCertificates(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+	server.RequireClientCert(ca)
+	g.Expect(server.StartHTTPS(cert, key, ca, "example.com")).To(Succeed())
+	defer server.StopHTTP()
+
+	u, err := url.Parse(server.HTTPAddress() + "/test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Without a client certificate, the handshake fails.
+	authOpts, err := git.NewAuthOptions(*u, map[string][]byte{"ca.crt": ca})
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), u.String(), repository.CloneConfig{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("certificate required"))
+
+	authOpts, err = git.NewAuthOptions(*u, map[string][]byte{
+		"ca.crt":  ca,
+		"tls.crt": cert,
+		"tls.key": key,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc, err = NewClient(t.TempDir(), authOpts, WithDiskStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), u.String(), repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = ggc.Commit(git.Commit{
+		Author:  git.Signature{Name: "Jane Doe", Email: "jane@example.com"},
+		Message: "Update file",
+	}, repository.WithFiles(map[string]io.Reader{"file.txt": strings.NewReader("content")}))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ggc.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+
+	authOpts.ClientKey = []byte("invalid")
+	_, err = ggc.Fetch(context.TODO(), u.String(), repository.CloneConfig{})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unable to load client certificate"))
+}
+
+func TestClient_clientCertPerClient(t *testing.T) {
+	g := NewWithT(t)
+
+	ca, cert, key := generateCertificates(t)
+	// The certificate of the other CA is not accepted by the server.
+	_, otherCert, otherKey := generateCertificates(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+	server.RequireClientCert(ca)
+	g.Expect(server.StartHTTPS(cert, key, ca, "example.com")).To(Succeed())
+	defer server.StopHTTP()
+
+	u, err := url.Parse(server.HTTPAddress() + "/test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	newClient := func(cert, key []byte) *Client {
+		authOpts, err := git.NewAuthOptions(*u, map[string][]byte{
+			"ca.crt":  ca,
+			"tls.crt": cert,
+			"tls.key": key,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage())
+		g.Expect(err).ToNot(HaveOccurred())
+		return ggc
+	}
+
+	// The connections of a client are not reused by a client with a
+	// different certificate, which must be rejected by the server.
+	accepted, rejected := newClient(cert, key), newClient(otherCert, otherKey)
+	_, err = accepted.Clone(context.TODO(), u.String(), repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	for i := 0; i < 2; i++ {
+		_, err = rejected.Fetch(context.TODO(), u.String(), repository.CloneConfig{})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("certificate"))
+		_, err = accepted.Fetch(context.TODO(), u.String(), repository.CloneConfig{})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+}
+
+// generateCertificates returns a PEM encoded CA certificate, and a
+// certificate and key signed by it for both server and client
+// authentication on 127.0.0.1.
+func generateCertificates(t *testing.T) (ca, cert, key []byte) {
+	t.Helper()
+	g := NewWithT(t)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate, caTemplate, &certKey.PublicKey, caKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func Test_providerAuth(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokenServer := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/app/installations/456/access_tokens" {
+			w.WriteHeader(nethttp.StatusNotFound)
+			return
+		}
+		w.WriteHeader(nethttp.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"token": "app-token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer tokenServer.Close()
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	server.Auth(github.AccessTokenUsername, "app-token")
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+
+	u, err := url.Parse(server.HTTPAddress() + "/test.git")
+	g.Expect(err).ToNot(HaveOccurred())
+	authOpts, err := git.NewAuthOptions(*u, map[string][]byte{
+		github.AppIDKey:             []byte("123"),
+		github.AppInstallationIDKey: []byte("456"),
+		github.AppPrivateKey:        keyPEM,
+		github.AppBaseURLKey:        []byte(tokenServer.URL),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	auth, err := transportAuth(context.TODO(), authOpts, git.CredentialsRequest{}, false, true)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(auth).To(Equal(&http.BasicAuth{
+		Username: github.AccessTokenUsername,
+		Password: "app-token",
+	}))
+
+	ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage(), WithInsecureCredentialsOverHTTP())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), u.String(), repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	authOpts.ProviderOpts.GitHubOpts = append(authOpts.ProviderOpts.GitHubOpts, github.WithInstallationID("789"))
+	_, err = transportAuth(context.TODO(), authOpts, git.CredentialsRequest{}, false, true)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unexpected status code 404"))
+
+	_, err = transportAuth(context.TODO(), &git.AuthOptions{
+		Transport:    git.HTTPS,
+		ProviderOpts: &git.ProviderOptions{Name: "foo"},
+	}, git.CredentialsRequest{}, false, false)
+	g.Expect(err).To(MatchError("unknown provider 'foo'"))
+}
+
+// rotatingCredentialsProvider returns stale credentials until it is asked
+// to refresh them.
+type rotatingCredentialsProvider struct {
+	mu            sync.Mutex
+	stale         bool
+	requests      []git.CredentialsRequest
+	username      string
+	password      string
+	stalePassword string
+}
+
+func (p *rotatingCredentialsProvider) Credentials(_ context.Context, req git.CredentialsRequest) (*git.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if req.Refresh {
+		p.stale = false
+	}
+	if p.stale {
+		return &git.Credentials{Username: p.username, Password: p.stalePassword}, nil
+	}
+	return &git.Credentials{Username: p.username, Password: p.password}, nil
+}
+
+func TestClient_credentialsProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	server, err := gittestserver.NewTempGitServer()
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(server.Root())
+	server.Auth("test-user", "new-pass")
+	g.Expect(server.InitRepo("../testdata/git/repo", git.DefaultBranch, "test.git")).To(Succeed())
+	g.Expect(server.StartHTTP()).To(Succeed())
+	defer server.StopHTTP()
+	repoURL := server.HTTPAddress() + "/test.git"
+
+	provider := &rotatingCredentialsProvider{
+		stale:         true,
+		username:      "test-user",
+		password:      "new-pass",
+		stalePassword: "old-pass",
+	}
+	authOpts := &git.AuthOptions{
+		Transport:           git.HTTP,
+		CredentialsProvider: provider,
+	}
+
+	// Credentials are not sent over HTTP without opting in.
+	ggc, err := NewClient(t.TempDir(), authOpts, WithDiskStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+	g.Expect(err).To(MatchError("credentials provider cannot be used over HTTP"))
+	g.Expect(provider.requests).To(BeEmpty())
+
+	// Neither are GitHub App installation tokens.
+	var tokenRequests atomic.Int32
+	tokenServer := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		tokenRequests.Add(1)
+		w.WriteHeader(nethttp.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"token": "app-token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer tokenServer.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	u, err := url.Parse(repoURL)
+	g.Expect(err).ToNot(HaveOccurred())
+	appAuthOpts, err := git.NewAuthOptions(*u, map[string][]byte{
+		github.AppIDKey:             []byte("123"),
+		github.AppInstallationIDKey: []byte("456"),
+		github.AppPrivateKey:        pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		github.AppBaseURLKey:        []byte(tokenServer.URL),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	ggc, err = NewClient(t.TempDir(), appAuthOpts, WithDiskStorage())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+	g.Expect(err).To(MatchError("credentials provider cannot be used over HTTP"))
+	g.Expect(tokenRequests.Load()).To(BeZero())
+
+	ggc, err = NewClient(t.TempDir(), authOpts, WithDiskStorage(), WithInsecureCredentialsOverHTTP())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(provider.requests).To(Equal([]git.CredentialsRequest{
+		{URL: repoURL, Operation: git.OperationRead},
+		{URL: repoURL, Operation: git.OperationRead, Refresh: true},
+	}))
+
+	_, err = ggc.Commit(git.Commit{
+		Author:  git.Signature{Name: "Jane Doe", Email: "jane@example.com"},
+		Message: "Update file",
+	}, repository.WithFiles(map[string]io.Reader{"file.txt": strings.NewReader("content")}))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	provider.mu.Lock()
+	provider.stale = true
+	provider.requests = nil
+	provider.mu.Unlock()
+	g.Expect(ggc.Push(context.TODO(), repository.PushConfig{})).To(Succeed())
+	g.Expect(provider.requests).To(Equal([]git.CredentialsRequest{
+		{URL: repoURL, Operation: git.OperationWrite},
+		{URL: repoURL, Operation: git.OperationWrite, Refresh: true},
+	}))
+
+	// Static credentials are not retried.
+	authOpts = &git.AuthOptions{
+		Transport: git.HTTP,
+		Username:  "test-user",
+		Password:  "old-pass",
+	}
+	ggc, err = NewClient(t.TempDir(), authOpts, WithDiskStorage(), WithInsecureCredentialsOverHTTP())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.Clone(context.TODO(), repoURL, repository.CloneConfig{})
+	g.Expect(err).To(HaveOccurred())
+}
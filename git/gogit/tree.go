@@ -0,0 +1,185 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// writeTree writes a new tree to the storer, which consists of the entries
+// of base with the given edits applied. The edits are keyed by the slash
+// separated path of the file, relative to the root of the tree. A nil entry
+// removes the file, while any other entry adds or replaces it with the Mode
+// and Hash of the entry. Directories left without entries are removed.
+// base may be nil, in which case the tree is built from the edits alone.
+// It returns the hash of the new tree.
+func writeTree(s storer.EncodedObjectStorer, base *object.Tree, edits map[string]*object.TreeEntry) (plumbing.Hash, error) {
+	tree, err := editTree(s, base, edits)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return writeTreeObject(s, tree)
+}
+
+// editTree returns the entries of base with the given edits applied, writing
+// any changed subtrees to the storer.
+func editTree(s storer.EncodedObjectStorer, base *object.Tree, edits map[string]*object.TreeEntry) (*object.Tree, error) {
+	entries := make(map[string]object.TreeEntry)
+	if base != nil {
+		for _, e := range base.Entries {
+			entries[e.Name] = e
+		}
+	}
+
+	subEdits := make(map[string]map[string]*object.TreeEntry)
+	for p, e := range edits {
+		dir, rest, found := strings.Cut(p, "/")
+		if !found {
+			if e == nil {
+				delete(entries, p)
+				continue
+			}
+			entries[p] = object.TreeEntry{Name: p, Mode: e.Mode, Hash: e.Hash}
+			continue
+		}
+		if subEdits[dir] == nil {
+			subEdits[dir] = make(map[string]*object.TreeEntry)
+		}
+		subEdits[dir][rest] = e
+	}
+
+	for dir, edits := range subEdits {
+		var subTree *object.Tree
+		if e, ok := entries[dir]; ok {
+			if e.Mode != filemode.Dir {
+				if onlyDeletions(edits) {
+					// There is nothing to delete below a file.
+					continue
+				}
+				return nil, notDirectoryError{Path: dir}
+			}
+			t, err := object.GetTree(s, e.Hash)
+			if err != nil {
+				return nil, err
+			}
+			subTree = t
+		}
+		t, err := editTree(s, subTree, edits)
+		if err != nil {
+			var nd notDirectoryError
+			if errors.As(err, &nd) {
+				nd.Path = dir + "/" + nd.Path
+				return nil, nd
+			}
+			return nil, err
+		}
+		if len(t.Entries) == 0 {
+			delete(entries, dir)
+			continue
+		}
+		hash, err := writeTreeObject(s, t)
+		if err != nil {
+			return nil, err
+		}
+		entries[dir] = object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash}
+	}
+
+	tree := &object.Tree{Entries: make([]object.TreeEntry, 0, len(entries))}
+	for _, e := range entries {
+		tree.Entries = append(tree.Entries, e)
+	}
+	// Git orders the entries of a tree by name, comparing the names of
+	// directories as if they end with a slash.
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return treeEntrySortName(tree.Entries[i]) < treeEntrySortName(tree.Entries[j])
+	})
+	return tree, nil
+}
+
+func onlyDeletions(edits map[string]*object.TreeEntry) bool {
+	for _, e := range edits {
+		if e != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTreeObject(s storer.EncodedObjectStorer, tree *object.Tree) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+func treeEntrySortName(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// findTreeEntry returns the entry at the given path of the tree, or nil if
+// the path does not exist. It returns a notDirectoryError if a parent of
+// the path is not a directory.
+func findTreeEntry(tree *object.Tree, path string) (*object.TreeEntry, error) {
+	if tree == nil {
+		return nil, nil
+	}
+	name, rest, found := strings.Cut(path, "/")
+	e, err := tree.FindEntry(name)
+	if errors.Is(err, object.ErrEntryNotFound) {
+		return nil, nil
+	}
+	if err != nil || !found {
+		return e, err
+	}
+	if e.Mode != filemode.Dir {
+		return nil, notDirectoryError{Path: name}
+	}
+	subTree, err := tree.Tree(name)
+	if err != nil {
+		return nil, err
+	}
+	e, err = findTreeEntry(subTree, rest)
+	var nd notDirectoryError
+	if errors.As(err, &nd) {
+		nd.Path = name + "/" + nd.Path
+		return nil, nd
+	}
+	return e, err
+}
+
+// notDirectoryError is returned when a path is below an entry of a tree
+// which is not a directory.
+type notDirectoryError struct {
+	// Path is the path of the entry which is not a directory.
+	Path string
+}
+
+func (e notDirectoryError) Error() string {
+	return fmt.Sprintf("'%s' is not a directory", e.Path)
+}
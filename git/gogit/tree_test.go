@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+)
+
+func Test_writeTree(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, f := range []string{"README.md", "apps/app.yaml", "apps/remove/remove.yaml", "infra/infra.yaml"} {
+		_, err = commitFile(repo, f, f, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	c, err := repo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := c.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	readme, err := base.FindEntry("README.md")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	hash, err := writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"apps/remove/remove.yaml": nil,
+		"apps/app.yaml":           nil,
+		"apps-readme.md":          readme,
+		"apps/new/README.md":      readme,
+		"infra/script.sh":         {Mode: filemode.Executable, Hash: readme.Hash},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	tree, err := repo.TreeObject(hash)
+	g.Expect(err).ToNot(HaveOccurred())
+	var names []string
+	g.Expect(tree.Files().ForEach(func(f *object.File) error {
+		names = append(names, f.Name)
+		return nil
+	})).To(Succeed())
+	g.Expect(names).To(ConsistOf("README.md", "apps-readme.md", "apps/new/README.md", "infra/infra.yaml", "infra/script.sh"))
+
+	// Entries are ordered as Git expects, with "apps" sorted as "apps/".
+	g.Expect(tree.Entries[1].Name).To(Equal("apps-readme.md"))
+	g.Expect(tree.Entries[2].Name).To(Equal("apps"))
+
+	script, err := tree.FindEntry("infra/script.sh")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(script.Mode).To(Equal(filemode.Executable))
+
+	_, err = tree.FindEntry("apps/remove")
+	g.Expect(err).To(HaveOccurred())
+
+	hash, err = writeTree(repo.Storer, nil, map[string]*object.TreeEntry{"README.md": nil})
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err = repo.TreeObject(hash)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(tree.Entries).To(BeEmpty())
+}
+
+func Test_writeTree_notDirectory(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	for _, f := range []string{"README.md", "apps/app.yaml"} {
+		_, err = commitFile(repo, f, f, time.Now())
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	c, err := repo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	base, err := c.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+	readme, err := base.FindEntry("README.md")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// A file can not be written below another file.
+	_, err = writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"apps/app.yaml/nested.yaml": readme,
+	})
+	g.Expect(err).To(MatchError("'apps/app.yaml' is not a directory"))
+
+	// Unless the file is replaced at the same time.
+	hash, err := writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"README.md":          nil,
+		"README.md/index.md": readme,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err := repo.TreeObject(hash)
+	g.Expect(err).ToNot(HaveOccurred())
+	e, err := tree.FindEntry("README.md/index.md")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e.Hash).To(Equal(readme.Hash))
+
+	// A directory replaced with a file retains the file.
+	hash, err = writeTree(repo.Storer, base, map[string]*object.TreeEntry{
+		"apps":          readme,
+		"apps/app.yaml": nil,
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err = repo.TreeObject(hash)
+	g.Expect(err).ToNot(HaveOccurred())
+	e, err = tree.FindEntry("apps")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e.Mode).To(Equal(filemode.Regular))
+}
+
+func Test_findTreeEntry(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = commitFile(repo, "apps/nested/app.yaml", "app", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	head, err := repo.Head()
+	g.Expect(err).ToNot(HaveOccurred())
+	c, err := repo.CommitObject(head.Hash())
+	g.Expect(err).ToNot(HaveOccurred())
+	tree, err := c.Tree()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	e, err := findTreeEntry(tree, "apps/nested/app.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e).ToNot(BeNil())
+	g.Expect(e.Name).To(Equal("app.yaml"))
+
+	e, err = findTreeEntry(tree, "apps/nested")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e.Mode).To(Equal(filemode.Dir))
+
+	e, err = findTreeEntry(tree, "apps/missing/app.yaml")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e).To(BeNil())
+
+	_, err = findTreeEntry(tree, "apps/nested/app.yaml/file")
+	g.Expect(err).To(MatchError("'apps/nested/app.yaml' is not a directory"))
+
+	e, err = findTreeEntry(nil, "apps")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e).To(BeNil())
+}
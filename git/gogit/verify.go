@@ -0,0 +1,189 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+// defaultVerifyMaxDepth is the default maximum number of commits walked by
+// VerifyCommits.
+const defaultVerifyMaxDepth = 1000
+
+// VerifyCommits verifies the signature of every commit reachable from HEAD
+// but not from the trusted base revision, as listed by
+// `git rev-list <base>..HEAD`, with the given key rings. The base revision
+// itself is not verified, and must be an ancestor of HEAD. Only the
+// ancestors of the base revision within the maximum depth are known to be
+// reachable from it, older ones are verified if reachable from HEAD.
+//
+// The commits are verified starting from the oldest, parents before their
+// children. The first commit that fails the verification is returned with
+// a git.ErrCommitVerification error. If HEAD can not be walked to the base
+// revision within the maximum depth, an error wrapping
+// git.ErrVerifyDepthExceeded is returned. For a shallow clone, the depth
+// of the clone must cover the commits after the base revision.
+func (g *Client) VerifyCommits(ctx context.Context, base string, keyRings []string, opts ...repository.VerifyOption) (*git.Commit, error) {
+	if g.repository == nil {
+		return nil, git.ErrNoGitRepository
+	}
+	o := &repository.VerifyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	maxDepth := o.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultVerifyMaxDepth
+	}
+
+	baseCommit, err := resolveCommit(g.repository, base)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve base revision '%s': %w", base, err)
+	}
+	head, err := resolveCommit(g.repository, plumbing.HEAD.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve HEAD: %w", err)
+	}
+	if head.Hash == baseCommit.Hash {
+		return nil, nil
+	}
+
+	trusted, err := commitAncestors(ctx, g.repository, baseCommit, maxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("unable to walk base revision '%s': %w", base, err)
+	}
+	if _, ok := trusted[head.Hash]; ok {
+		return nil, fmt.Errorf("base revision '%s' is not an ancestor of HEAD", base)
+	}
+	commits, err := commitRange(ctx, g.repository, head, baseCommit.Hash, trusted, maxDepth)
+	if err != nil {
+		if errors.Is(err, git.ErrVerifyDepthExceeded) {
+			return nil, fmt.Errorf("unable to reach base revision '%s' within %d commits: %w", base, maxDepth, err)
+		}
+		return nil, err
+	}
+	if commits == nil {
+		return nil, fmt.Errorf("base revision '%s' is not an ancestor of HEAD", base)
+	}
+
+	for _, c := range commits {
+		cc, err := buildCommitWithRef(c, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err = cc.Verify(keyRings...); err != nil {
+			return cc, git.ErrCommitVerification{Commit: c.Hash.String(), Err: err}
+		}
+	}
+	return nil, nil
+}
+
+// commitAncestors returns the set of the hashes of the given commit and
+// its ancestors, nearest first, of which at most maxDepth are walked.
+// Ancestors beyond maxDepth are left out, and are therefore verified when
+// reachable from HEAD. Parents missing from the object storage, for example
+// beyond the depth of a shallow clone, are skipped.
+func commitAncestors(ctx context.Context, repo *extgogit.Repository, c *object.Commit,
+	maxDepth int) (map[plumbing.Hash]struct{}, error) {
+	seen := map[plumbing.Hash]struct{}{c.Hash: {}}
+	queue := []*object.Commit{c}
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, queue = queue[0], queue[1:]
+		for _, h := range c.ParentHashes {
+			if _, ok := seen[h]; ok {
+				continue
+			}
+			if len(seen) > maxDepth {
+				return seen, nil
+			}
+			seen[h] = struct{}{}
+			p, err := repo.CommitObject(h)
+			if err != nil {
+				if errors.Is(err, plumbing.ErrObjectNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			queue = append(queue, p)
+		}
+	}
+	return seen, nil
+}
+
+// commitRange returns the commits reachable from head and not in the set
+// of trusted commits, with parents before their children. It returns nil
+// if base is not reached. At most maxDepth commits are walked, after
+// which git.ErrVerifyDepthExceeded is returned.
+func commitRange(ctx context.Context, repo *extgogit.Repository, head *object.Commit, base plumbing.Hash,
+	trusted map[plumbing.Hash]struct{}, maxDepth int) ([]*object.Commit, error) {
+	type frame struct {
+		commit *object.Commit
+		next   int
+	}
+
+	var commits []*object.Commit
+	var reached bool
+	visited := map[plumbing.Hash]struct{}{head.Hash: {}}
+	stack := []frame{{commit: head}}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f := &stack[len(stack)-1]
+		if f.next == len(f.commit.ParentHashes) {
+			commits = append(commits, f.commit)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		h := f.commit.ParentHashes[f.next]
+		f.next++
+		if h == base {
+			reached = true
+		}
+		if _, ok := trusted[h]; ok {
+			continue
+		}
+		if _, ok := visited[h]; ok {
+			continue
+		}
+		if len(visited) >= maxDepth {
+			return nil, git.ErrVerifyDepthExceeded
+		}
+		p, err := repo.CommitObject(h)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve parent '%s' of commit '%s': %w", h, f.commit.Hash, err)
+		}
+		visited[h] = struct{}{}
+		stack = append(stack, frame{commit: p})
+	}
+	if !reached {
+		return nil, nil
+	}
+	return commits, nil
+}
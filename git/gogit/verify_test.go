@@ -0,0 +1,230 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gogit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	extgogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git"
+	"github.com/fluxcd/pkg/git/repository"
+)
+
+func TestClient_VerifyCommits(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	root, err := commitFile(repo, "file", "root", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	signer, keyRing := newKeyRing(t, "Test User")
+	_, otherKeyRing := newKeyRing(t, "Other User")
+
+	// Build the history:
+	//
+	//   root - a - b - c - m - t
+	//       \         /
+	//        -- s ----
+	//
+	// where b and s are not signed.
+	a := signedCommit(t, repo, signer, "a", root)
+	b := signedCommit(t, repo, nil, "b", a)
+	c := signedCommit(t, repo, signer, "c", b)
+	s := signedCommit(t, repo, nil, "s", root)
+	m := signedCommit(t, repo, signer, "m", c, s)
+	head := signedCommit(t, repo, signer, "t", m)
+	unrelated := signedCommit(t, repo, signer, "x", c)
+	g.Expect(repo.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, head))).To(Succeed())
+
+	ggc, err := NewClient(t.TempDir(), nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	_, err = ggc.VerifyCommits(context.TODO(), root.String(), []string{keyRing})
+	g.Expect(err).To(Equal(git.ErrNoGitRepository))
+	ggc.repository = repo
+
+	tests := []struct {
+		name       string
+		base       string
+		keyRings   []string
+		opts       []repository.VerifyOption
+		wantCommit plumbing.Hash
+		wantErr    string
+	}{
+		{
+			name:     "verifies the commits after the base revision",
+			base:     m.String(),
+			keyRings: []string{keyRing},
+		},
+		{
+			name:     "verifies nothing if the base revision is HEAD",
+			base:     "HEAD",
+			keyRings: []string{keyRing},
+		},
+		{
+			name:       "fails on the unsigned commit of a merged branch",
+			base:       c.String(),
+			keyRings:   []string{keyRing},
+			wantCommit: s,
+		},
+		{
+			name:       "fails on the oldest unsigned commit",
+			base:       s.String(),
+			keyRings:   []string{keyRing},
+			wantCommit: b,
+		},
+		{
+			name:       "fails on a commit signed with an unknown key",
+			base:       m.String(),
+			keyRings:   []string{otherKeyRing},
+			wantCommit: head,
+		},
+		{
+			name:     "verifies within the maximum depth",
+			base:     m.String(),
+			keyRings: []string{keyRing},
+			opts:     []repository.VerifyOption{repository.WithVerifyMaxDepth(1)},
+		},
+		{
+			name:     "errors when exceeding the maximum depth",
+			base:     c.String(),
+			keyRings: []string{keyRing},
+			opts:     []repository.VerifyOption{repository.WithVerifyMaxDepth(2)},
+			wantErr:  "unable to reach base revision '" + c.String() + "' within 2 commits: maximum verification depth exceeded",
+		},
+		{
+			name:     "errors if the base revision is not an ancestor",
+			base:     unrelated.String(),
+			keyRings: []string{keyRing},
+			wantErr:  "base revision '" + unrelated.String() + "' is not an ancestor of HEAD",
+		},
+		{
+			name:     "errors on unknown base revision",
+			base:     "invalid",
+			keyRings: []string{keyRing},
+			wantErr:  "unable to resolve base revision 'invalid'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cc, err := ggc.VerifyCommits(context.TODO(), tt.base, tt.keyRings, tt.opts...)
+			switch {
+			case tt.wantErr != "":
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+				g.Expect(cc).To(BeNil())
+			case !tt.wantCommit.IsZero():
+				var verr git.ErrCommitVerification
+				g.Expect(errors.As(err, &verr)).To(BeTrue(), "unexpected error: %v", err)
+				g.Expect(verr.Commit).To(Equal(tt.wantCommit.String()))
+				g.Expect(cc).ToNot(BeNil())
+				g.Expect(cc.Hash.String()).To(Equal(tt.wantCommit.String()))
+			default:
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(cc).To(BeNil())
+			}
+		})
+	}
+
+	_, err = ggc.VerifyCommits(context.TODO(), root.String(), []string{keyRing}, repository.WithVerifyMaxDepth(1))
+	g.Expect(errors.Is(err, git.ErrVerifyDepthExceeded)).To(BeTrue())
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = ggc.VerifyCommits(ctx, c.String(), []string{keyRing})
+	g.Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+}
+
+func Test_commitAncestors(t *testing.T) {
+	g := NewWithT(t)
+
+	repo, _, err := initRepo(t.TempDir())
+	g.Expect(err).ToNot(HaveOccurred())
+	root, err := commitFile(repo, "file", "root", time.Now())
+	g.Expect(err).ToNot(HaveOccurred())
+	a := signedCommit(t, repo, nil, "a", root)
+	b := signedCommit(t, repo, nil, "b", root)
+	m := signedCommit(t, repo, nil, "m", a, b)
+	c, err := repo.CommitObject(m)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	ancestors, err := commitAncestors(context.TODO(), repo, c, 10)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ancestors).To(HaveLen(4))
+	g.Expect(ancestors).To(HaveKey(root))
+
+	// The nearest ancestors are walked first.
+	ancestors, err = commitAncestors(context.TODO(), repo, c, 2)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(ancestors).To(HaveLen(3))
+	g.Expect(ancestors).To(And(HaveKey(m), HaveKey(a), HaveKey(b)))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = commitAncestors(ctx, repo, c, 10)
+	g.Expect(err).To(MatchError(context.Canceled))
+}
+
+// newKeyRing returns a new OpenPGP entity, and its armored public key ring.
+func newKeyRing(t *testing.T, name string) (*openpgp.Entity, string) {
+	g := NewWithT(t)
+
+	entity, err := openpgp.NewEntity(name, "", "test@example.com", nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	var keyRing bytes.Buffer
+	w, err := armor.Encode(&keyRing, openpgp.PublicKeyType, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entity.Serialize(w)).To(Succeed())
+	g.Expect(w.Close()).To(Succeed())
+	return entity, keyRing.String()
+}
+
+// signedCommit writes a commit with the tree of the first parent and the
+// given message, signed with the signer if not nil.
+func signedCommit(t *testing.T, repo *extgogit.Repository, signer *openpgp.Entity, msg string, parents ...plumbing.Hash) plumbing.Hash {
+	g := NewWithT(t)
+
+	parent, err := repo.CommitObject(parents[0])
+	g.Expect(err).ToNot(HaveOccurred())
+	commit := &object.Commit{
+		Author:       *mockSignature(time.Now()),
+		Committer:    *mockSignature(time.Now()),
+		Message:      msg,
+		TreeHash:     parent.TreeHash,
+		ParentHashes: parents,
+	}
+	if signer != nil {
+		commit.PGPSignature, err = signCommit(commit, signer)
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	obj := repo.Storer.NewEncodedObject()
+	g.Expect(commit.Encode(obj)).To(Succeed())
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	g.Expect(err).ToNot(HaveOccurred())
+	return hash
+}
@@ -19,12 +19,21 @@ package git
 import (
 	"fmt"
 	"net/url"
+
+	"github.com/fluxcd/pkg/git/github"
 )
 
 const (
 	DefaultRemote            = "origin"
 	DefaultBranch            = "master"
 	DefaultPublicKeyAuthUser = "git"
+	DefaultNotesRef          = "refs/notes/commits"
+)
+
+const (
+	// ProviderGitHub is the name of the provider which authenticates as a
+	// GitHub App installation.
+	ProviderGitHub = "github"
 )
 
 type TransportType string
@@ -44,8 +53,31 @@ type AuthOptions struct {
 	Password    string
 	BearerToken string
 	Identity    []byte
-	KnownHosts  []byte
-	CAFile      []byte
+	// IdentityCert is the OpenSSH user certificate of the Identity, in
+	// authorized_keys format. If set, it is presented to the server instead
+	// of the public key of the Identity.
+	IdentityCert []byte
+	KnownHosts   []byte
+	CAFile       []byte
+	// ClientCert and ClientKey are the PEM encoded certificate and private
+	// key used for TLS client authentication over HTTPS.
+	ClientCert []byte
+	ClientKey  []byte
+	// ProviderOpts configures a built-in provider which supplies the
+	// credentials for HTTP(S) transports, instead of static values.
+	ProviderOpts *ProviderOptions
+	// CredentialsProvider supplies the credentials for HTTP(S) transports
+	// on demand, and takes precedence over ProviderOpts and the static
+	// credentials.
+	CredentialsProvider CredentialsProvider
+}
+
+// ProviderOptions contains the options of a credentials provider.
+type ProviderOptions struct {
+	// Name is the name of the provider, for example ProviderGitHub.
+	Name string
+	// GitHubOpts configures the client of the ProviderGitHub provider.
+	GitHubOpts []github.OptFunc
 }
 
 // KexAlgos hosts the key exchange algorithms to be used for SSH connections.
@@ -63,6 +95,12 @@ func (o AuthOptions) Validate() error {
 		if o.Username == "" && o.Password != "" {
 			return fmt.Errorf("invalid '%s' auth option: 'password' requires 'username' to be set", o.Transport)
 		}
+		if o.ProviderOpts != nil && o.ProviderOpts.Name != ProviderGitHub {
+			return fmt.Errorf("invalid '%s' auth option: unknown provider '%s'", o.Transport, o.ProviderOpts.Name)
+		}
+		if (len(o.ClientCert) == 0) != (len(o.ClientKey) == 0) {
+			return fmt.Errorf("invalid '%s' auth option: client certificate and key must be set together", o.Transport)
+		}
 	case SSH:
 		if o.Host == "" {
 			return fmt.Errorf("invalid '%s' auth option: 'host' is required", o.Transport)
@@ -73,6 +111,14 @@ func (o AuthOptions) Validate() error {
 		if len(o.KnownHosts) == 0 {
 			return fmt.Errorf("invalid '%s' auth option: 'known_hosts' is required", o.Transport)
 		}
+		if o.ProviderOpts != nil || o.CredentialsProvider != nil {
+			return fmt.Errorf("invalid '%s' auth option: providers are not supported", o.Transport)
+		}
+		if len(o.IdentityCert) > 0 {
+			if _, err := ParseSSHUserCertificate(o.IdentityCert); err != nil {
+				return fmt.Errorf("invalid '%s' auth option: %w", o.Transport, err)
+			}
+		}
 	case "":
 		return fmt.Errorf("no transport type set")
 	default:
@@ -94,6 +140,7 @@ func NewAuthOptions(u url.URL, data map[string][]byte) (*AuthOptions, error) {
 		}
 		if opts.Transport == SSH {
 			opts.Identity = data["identity"]
+			opts.IdentityCert = data["identity-cert.pub"]
 			opts.KnownHosts = data["known_hosts"]
 			opts.Username = u.User.Username()
 			opts.Password = string(data["password"])
@@ -103,13 +150,27 @@ func NewAuthOptions(u url.URL, data map[string][]byte) (*AuthOptions, error) {
 			if opts.Username == "" {
 				opts.Username = DefaultPublicKeyAuthUser
 			}
-		} else if token, found := data["bearerToken"]; found {
-			opts.CAFile = caBytes
-			opts.BearerToken = string(token)
 		} else {
 			opts.CAFile = caBytes
-			opts.Username = string(data["username"])
-			opts.Password = string(data["password"])
+			// tls.crt and tls.key take precedence over certFile and keyFile.
+			if opts.ClientCert = data["tls.crt"]; len(opts.ClientCert) == 0 {
+				opts.ClientCert = data["certFile"]
+			}
+			if opts.ClientKey = data["tls.key"]; len(opts.ClientKey) == 0 {
+				opts.ClientKey = data["keyFile"]
+			}
+
+			if _, found := data[github.AppIDKey]; found {
+				opts.ProviderOpts = &ProviderOptions{
+					Name:       ProviderGitHub,
+					GitHubOpts: []github.OptFunc{github.WithAppData(data)},
+				}
+			} else if token, found := data["bearerToken"]; found {
+				opts.BearerToken = string(token)
+			} else {
+				opts.Username = string(data["username"])
+				opts.Password = string(data["password"])
+			}
 		}
 	}
 
@@ -21,6 +21,8 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+
+	"github.com/fluxcd/pkg/git/github"
 )
 
 const (
@@ -106,6 +108,59 @@ func TestAuthOptions_Validate(t *testing.T) {
 				Transport: HTTPS,
 			},
 		},
+		{
+			name: "Valid HTTPS transport with GitHub provider",
+			opts: AuthOptions{
+				Transport:    HTTPS,
+				ProviderOpts: &ProviderOptions{Name: ProviderGitHub},
+			},
+		},
+		{
+			name: "HTTPS transport with unknown provider",
+			opts: AuthOptions{
+				Transport:    HTTPS,
+				ProviderOpts: &ProviderOptions{Name: "foo"},
+			},
+			wantErr: "invalid 'https' auth option: unknown provider 'foo'",
+		},
+		{
+			name: "SSH transport with provider",
+			opts: AuthOptions{
+				Transport:    SSH,
+				Host:         "github.com:22",
+				Identity:     []byte(privateKeyFixture),
+				KnownHosts:   []byte(knownHostsFixture),
+				ProviderOpts: &ProviderOptions{Name: ProviderGitHub},
+			},
+			wantErr: "invalid 'ssh' auth option: providers are not supported",
+		},
+		{
+			name: "HTTPS transport with client certificate requires key",
+			opts: AuthOptions{
+				Transport:  HTTPS,
+				ClientCert: []byte("cert"),
+			},
+			wantErr: "invalid 'https' auth option: client certificate and key must be set together",
+		},
+		{
+			name: "HTTPS transport with client key requires certificate",
+			opts: AuthOptions{
+				Transport: HTTPS,
+				ClientKey: []byte("key"),
+			},
+			wantErr: "invalid 'https' auth option: client certificate and key must be set together",
+		},
+		{
+			name: "SSH transport with invalid identity certificate",
+			opts: AuthOptions{
+				Transport:    SSH,
+				Host:         "github.com:22",
+				Identity:     []byte(privateKeyFixture),
+				KnownHosts:   []byte(knownHostsFixture),
+				IdentityCert: []byte("invalid"),
+			},
+			wantErr: "invalid 'ssh' auth option: unable to parse SSH certificate: ssh: no key found",
+		},
 		{
 			name: "SSH transport requires host",
 			opts: AuthOptions{
@@ -221,6 +276,27 @@ func TestAuthOptionsFromData(t *testing.T) {
 				g.Expect(opts.CAFile).To(BeEquivalentTo("mock"))
 			},
 		},
+		{
+			name: "Sets provider options from Secret for HTTPS with GitHub App",
+			URL:  "https://github.com/org/repo",
+			data: map[string][]byte{
+				"username":                  []byte("example"),
+				"password":                  []byte("secret"),
+				github.AppIDKey:             []byte("123"),
+				github.AppInstallationIDKey: []byte("456"),
+				github.AppPrivateKey:        []byte(privateKeyFixture),
+				"ca.crt":                    []byte("mock"),
+			},
+
+			wantFunc: func(g *WithT, opts *AuthOptions) {
+				g.Expect(opts.Username).To(Equal(""))
+				g.Expect(opts.Password).To(Equal(""))
+				g.Expect(opts.CAFile).To(BeEquivalentTo("mock"))
+				g.Expect(opts.ProviderOpts).ToNot(BeNil())
+				g.Expect(opts.ProviderOpts.Name).To(Equal(ProviderGitHub))
+				g.Expect(opts.ProviderOpts.GitHubOpts).To(HaveLen(1))
+			},
+		},
 		{
 			name: "Sets only relevant values from Secret for SSH",
 			URL:  "ssh://example.com",
@@ -295,6 +371,50 @@ func TestAuthOptionsFromData(t *testing.T) {
 				g.Expect(opts.CAFile).To(BeEquivalentTo("mock"))
 			},
 		},
+		{
+			name: "Sets client certificate for HTTPS using certFile and keyFile",
+			URL:  "https://example.com",
+			data: map[string][]byte{
+				"certFile": []byte("cert"),
+				"keyFile":  []byte("key"),
+				"username": []byte("user"),
+			},
+			wantFunc: func(g *WithT, opts *AuthOptions) {
+				g.Expect(opts.ClientCert).To(BeEquivalentTo("cert"))
+				g.Expect(opts.ClientKey).To(BeEquivalentTo("key"))
+				g.Expect(opts.Username).To(Equal("user"))
+			},
+		},
+		{
+			name: "Sets client certificate for HTTPS using tls.crt and tls.key and ignores certFile and keyFile",
+			URL:  "https://example.com",
+			data: map[string][]byte{
+				"tls.crt":     []byte("cert"),
+				"tls.key":     []byte("key"),
+				"certFile":    []byte("ignored"),
+				"keyFile":     []byte("ignored"),
+				"bearerToken": []byte("token"),
+			},
+			wantFunc: func(g *WithT, opts *AuthOptions) {
+				g.Expect(opts.ClientCert).To(BeEquivalentTo("cert"))
+				g.Expect(opts.ClientKey).To(BeEquivalentTo("key"))
+				g.Expect(opts.BearerToken).To(Equal("token"))
+			},
+		},
+		{
+			name: "Does not set client certificate for SSH",
+			URL:  "ssh://git@example.com",
+			data: map[string][]byte{
+				"identity":    []byte(privateKeyFixture),
+				"known_hosts": []byte(knownHostsFixture),
+				"tls.crt":     []byte("cert"),
+				"tls.key":     []byte("key"),
+			},
+			wantFunc: func(g *WithT, opts *AuthOptions) {
+				g.Expect(opts.ClientCert).To(BeNil())
+				g.Expect(opts.ClientKey).To(BeNil())
+			},
+		},
 		{
 			name: "Sets transport from URL",
 			URL:  "http://git@example.com",
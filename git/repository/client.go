@@ -38,6 +38,32 @@ type Reader interface {
 	Closer
 }
 
+// Fetcher knows how to incrementally update a Git repository from a remote.
+// It is optional, implementations of Reader can be type asserted to it.
+type Fetcher interface {
+	// Fetch updates the repository at the configured path to the target of
+	// the CheckoutStrategy, by fetching only the objects missing locally from
+	// the provided url. It returns a Commit object describing the Git commit
+	// that the repository HEAD points to. Local modifications to the
+	// worktree are discarded. If no repository exists at the path, it is
+	// cloned instead.
+	Fetch(ctx context.Context, url string, cfg CloneConfig) (*git.Commit, error)
+}
+
+// HistoryReader knows how to inspect the history of a Git repository.
+// It is optional, implementations of Reader can be type asserted to it.
+type HistoryReader interface {
+	// CommitsBetween returns the commits reachable from the head revision,
+	// but not from the base revision, ordered from newest to oldest.
+	// Revisions can be a commit hash, branch or tag name. If base is empty,
+	// all commits reachable from head are returned.
+	CommitsBetween(base, head string) ([]*git.Commit, error)
+	// ChangedFiles returns the files that changed between the trees of the
+	// base and head revisions, ordered by path. If base is empty, all the
+	// files of head are returned as added.
+	ChangedFiles(base, head string) ([]git.FileChange, error)
+}
+
 // Writer knows how to perform local and remote write operations
 // on a Git repository.
 type Writer interface {
@@ -57,6 +83,19 @@ type Writer interface {
 	Closer
 }
 
+// Tagger knows how to create tags in a Git repository.
+// It is optional, implementations of Writer can be type asserted to it.
+type Tagger interface {
+	// Tag creates a tag with the name of the provided info on the given
+	// commit, or HEAD if commit is empty. If the info has a message, an
+	// annotated tag is created with the Author of the info as tagger,
+	// otherwise the tag is lightweight. tagOpts is an optional argument
+	// which can be provided to configure the tag.
+	// The tag can be pushed to the origin using Push with a PushConfig
+	// refspec, for example "refs/tags/v1.0.0:refs/tags/v1.0.0".
+	Tag(info git.Tag, commit string, tagOpts ...TagOption) (*git.Tag, error)
+}
+
 // Closer knows how to perform any operations that need to happen
 // at the end of the lifecycle of a Writer/Reader.
 // When this is not required by the implementation, it can simply embed an
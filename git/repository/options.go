@@ -38,6 +38,22 @@ type CloneConfig struct {
 	// not supported by all Implementations.
 	RecurseSubmodules bool
 
+	// AllowedSubmoduleURLs is a list of hosts, with an optional port, or
+	// URL prefixes the URLs of the submodules must match when
+	// RecurseSubmodules is set. A URL prefix matches URLs with the same
+	// scheme, host and port, of which the path equals or is below the path
+	// of the prefix. If a submodule does not match any of them, the clone
+	// fails. If empty, the URLs of the submodules are not restricted.
+	// Not supported by all implementations.
+	AllowedSubmoduleURLs []string
+
+	// PinSubmodules defines if only the commits recorded by the gitlinks of
+	// the submodules should be fetched when RecurseSubmodules is set,
+	// instead of all their branches. The remotes of the submodules must
+	// allow fetching commits by hash.
+	// Not supported by all implementations.
+	PinSubmodules bool
+
 	// LastObservedCommit holds the last observed commit hash of a
 	// Git repository.
 	// If provided, the clone operation will compare it with the HEAD commit
@@ -48,9 +64,37 @@ type CloneConfig struct {
 	// to checkout.
 	LastObservedCommit string
 
+	// IncludePaths is a list of file or directory paths, relative to the
+	// root of the repository, used in combination with LastObservedCommit.
+	// If set, and the changes between the last observed commit and the
+	// cloned HEAD commit do not touch any of these paths, a "non-concrete"
+	// commit is returned for HEAD. If the last observed commit can not be
+	// found, the changes are assumed to be relevant.
+	// Not supported by all implementations.
+	IncludePaths []string
+
 	// ShallowClone defines if the repository should be shallow cloned,
 	// not supported by all implementations
 	ShallowClone bool
+
+	// SparseCheckoutDirectories is a list of directory paths, relative to
+	// the root of the repository, to check out. If set, only the files in
+	// these directories are written to the worktree, while the other files
+	// are marked as skipped in the index. It can be combined with
+	// ShallowClone to minimise network and disk usage.
+	// It can not be combined with RecurseSubmodules.
+	// Not supported by all implementations.
+	SparseCheckoutDirectories []string
+
+	// LFS defines if Git LFS pointer files in the checked out tree should be
+	// replaced with the objects they point to, downloaded through the LFS
+	// batch API of the remote using the same authentication options.
+	// Not supported by all implementations.
+	LFS bool
+
+	// LFSMaxSize is the maximum size in bytes of a single Git LFS object.
+	// If an object exceeds it, the checkout fails. Zero means no limit.
+	LFSMaxSize int64
 }
 
 // PushConfig provides configuration options for a Git push.
@@ -63,6 +107,25 @@ type PushConfig struct {
 	// Force, if set to true, will result in a force push.
 	Force bool
 
+	// RebaseRetries is the number of times a push of the current branch
+	// that is rejected as a non-fast-forward update is retried, after the
+	// local commits have been re-applied on top of the remote branch. If a
+	// commit changes paths that were also changed on the remote branch, the
+	// push fails with a git.ErrRebaseConflict. It is ignored when Force is
+	// set or Refspecs are provided. Defaults to zero, which disables retries.
+	RebaseRetries int
+
+	// Signer is used to sign the re-applied commits of RebaseRetries whose
+	// original commits are signed. If not set, the rebase of a signed commit
+	// fails with a git.ErrRebaseSignedCommit.
+	Signer *openpgp.Entity
+
+	// NotesRefs is a list of notes references, for example
+	// "refs/notes/commits", which are pushed along with the current branch
+	// or the Refspecs. A notes reference rejected as a non-fast-forward
+	// update is not retried, as it can not be rebased.
+	NotesRefs []string
+
 	// Options is a map specifying the push options that are sent
 	// to the Git server when performing a push option. For details, see:
 	// https://git-scm.com/docs/git-push#Documentation/git-push.txt---push-optionltoptiongt
@@ -99,6 +162,30 @@ type CommitOptions struct {
 	// Files contains file names mapped to the file's content.
 	// Its used to write files which are then included in the commit.
 	Files map[string]io.Reader
+	// DeletedFiles contains the paths of files or directories which are
+	// deleted in the commit.
+	DeletedFiles []string
+	// RenamedFiles contains the paths of files or directories mapped to
+	// the path they are moved to in the commit.
+	RenamedFiles map[string]string
+	// Trailers contains the trailers which are appended to the commit
+	// message, for example "Signed-off-by".
+	Trailers []Trailer
+}
+
+// Trailer is a Git trailer, a "key: value" line at the end of a commit
+// message. For details, see:
+// https://git-scm.com/docs/git-interpret-trailers
+type Trailer struct {
+	// Key of the trailer, for example "Signed-off-by".
+	Key string
+	// Value of the trailer, for example "Jane Doe <jane@example.com>".
+	Value string
+}
+
+// String returns the trailer in the format of "key: value".
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
 }
 
 // CommitOption defines an option for a commit operation.
@@ -121,3 +208,156 @@ func WithFiles(files map[string]io.Reader) CommitOption {
 		co.Files = files
 	}
 }
+
+// WithDeletedFiles instructs the Git client to delete the files or
+// directories at the provided paths, and include the deletions in the
+// commit. Deletions are applied before renames and written files.
+func WithDeletedFiles(paths ...string) CommitOption {
+	return func(co *CommitOptions) {
+		co.DeletedFiles = append(co.DeletedFiles, paths...)
+	}
+}
+
+// WithRenamedFiles instructs the Git client to move the files or
+// directories and include the renames in the commit.
+// renames contains the current paths as its key and the new paths as the
+// value. The new paths must not exist, and the paths of a rename must not
+// overlap with those of another, for example in chained renames. Renames
+// are applied before the files of WithFiles are written, which allows to
+// change their content.
+func WithRenamedFiles(renames map[string]string) CommitOption {
+	return func(co *CommitOptions) {
+		co.RenamedFiles = renames
+	}
+}
+
+// WithTrailers instructs the Git client to append the provided trailers to
+// the commit message. Trailers which are already present in the message are
+// not repeated.
+func WithTrailers(trailers ...Trailer) CommitOption {
+	return func(co *CommitOptions) {
+		co.Trailers = append(co.Trailers, trailers...)
+	}
+}
+
+// TagOptions provides options to configure a Git tag operation.
+type TagOptions struct {
+	// Signer can be used to sign an annotated tag using OpenPGP.
+	Signer *openpgp.Entity
+}
+
+// TagOption defines an option for a tag operation.
+type TagOption func(*TagOptions)
+
+// WithTagSigner allows for the annotated tag to be signed using the
+// provided OpenPGP signer.
+func WithTagSigner(signer *openpgp.Entity) TagOption {
+	return func(to *TagOptions) {
+		to.Signer = signer
+	}
+}
+
+// ArchiveOptions provides options to configure a Git archive operation.
+type ArchiveOptions struct {
+	// Path is the directory, relative to the root of the repository, of
+	// which the contents are archived. Defaults to the root.
+	Path string
+	// Ignore contains patterns in the .gitignore format, as used in
+	// .sourceignore files, of paths which are excluded from the archive.
+	// The patterns are relative to Path.
+	Ignore []string
+	// IgnoreFile is the name of the files in the archived tree which
+	// contain additional ignore patterns, for example ".sourceignore".
+	// As with .gitignore files, the patterns of a file are relative to
+	// its directory, and take precedence over those of parent directories.
+	IgnoreFile string
+	// DefaultIgnore defines if the VCS and default patterns of the
+	// sourceignore package are excluded from the archive, with a lower
+	// precedence than Ignore and the patterns of the IgnoreFile.
+	DefaultIgnore bool
+}
+
+// ArchiveOption defines an option for an archive operation.
+type ArchiveOption func(*ArchiveOptions)
+
+// WithArchivePath instructs the Git client to only archive the contents of
+// the directory at the provided path.
+func WithArchivePath(path string) ArchiveOption {
+	return func(ao *ArchiveOptions) {
+		ao.Path = path
+	}
+}
+
+// WithArchiveIgnore instructs the Git client to exclude the paths matching
+// the provided patterns from the archive.
+func WithArchiveIgnore(patterns ...string) ArchiveOption {
+	return func(ao *ArchiveOptions) {
+		ao.Ignore = append(ao.Ignore, patterns...)
+	}
+}
+
+// WithArchiveIgnoreFile instructs the Git client to read additional ignore
+// patterns from the files with the provided name in the archived tree.
+func WithArchiveIgnoreFile(name string) ArchiveOption {
+	return func(ao *ArchiveOptions) {
+		ao.IgnoreFile = name
+	}
+}
+
+// WithArchiveDefaultIgnore instructs the Git client to exclude the paths
+// matching the VCS and default patterns of the sourceignore package from
+// the archive.
+func WithArchiveDefaultIgnore() ArchiveOption {
+	return func(ao *ArchiveOptions) {
+		ao.DefaultIgnore = true
+	}
+}
+
+// NoteOptions provides options to configure a Git notes operation.
+type NoteOptions struct {
+	// Ref is the notes reference the notes are read from or written to,
+	// for example "refs/notes/commits". A name without the "refs/notes/"
+	// prefix is expanded, as done by Git. Defaults to "refs/notes/commits".
+	Ref string
+	// Append defines if a note is appended to the existing note of the
+	// commit, separated by a blank line, instead of replacing it.
+	Append bool
+}
+
+// NoteOption defines an option for a notes operation.
+type NoteOption func(*NoteOptions)
+
+// WithNotesRef instructs the Git client to use the provided notes
+// reference instead of the default "refs/notes/commits".
+func WithNotesRef(ref string) NoteOption {
+	return func(no *NoteOptions) {
+		no.Ref = ref
+	}
+}
+
+// WithNoteAppend instructs the Git client to append the note to the
+// existing note of the commit, instead of replacing it.
+func WithNoteAppend() NoteOption {
+	return func(no *NoteOptions) {
+		no.Append = true
+	}
+}
+
+// VerifyOptions provides options to configure the verification of a range
+// of commits.
+type VerifyOptions struct {
+	// MaxDepth is the maximum number of commits walked from HEAD to the
+	// base revision. Defaults to 1000.
+	MaxDepth int
+}
+
+// VerifyOption defines an option for a verify operation.
+type VerifyOption func(*VerifyOptions)
+
+// WithVerifyMaxDepth instructs the Git client to walk at most the provided
+// number of commits from HEAD to the base revision.
+func WithVerifyMaxDepth(depth int) VerifyOption {
+	return func(vo *VerifyOptions) {
+		vo.MaxDepth = depth
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshSignatureMagic is the magic preamble of an SSH signature, as
+	// defined in https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig.
+	sshSignatureMagic = "SSHSIG"
+	// sshSignatureVersion is the only supported version of the SSH
+	// signature format.
+	sshSignatureVersion = 1
+	// sshSignaturePEMType is the PEM block type of an armored SSH signature.
+	sshSignaturePEMType = "SSH SIGNATURE"
+	// sshSignatureNamespace is the namespace Git uses when creating SSH
+	// signatures.
+	sshSignatureNamespace = "git"
+)
+
+// IsSSHSignature returns true if the provided signature is an armored
+// SSH signature, as created by Git when 'gpg.format' is set to 'ssh'.
+func IsSSHSignature(sig string) bool {
+	return strings.HasPrefix(strings.TrimSpace(sig), "-----BEGIN "+sshSignaturePEMType+"-----")
+}
+
+// sshSignature is the decoded form of an armored SSH signature.
+type sshSignature struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data blob that is signed by the private key.
+type sshSignedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// allowedSigner is an entry of an allowed signers file, as described in
+// the "ALLOWED SIGNERS" section of ssh-keygen(1).
+type allowedSigner struct {
+	Principals []string
+	Namespaces []string
+	PublicKey  ssh.PublicKey
+}
+
+func verifySSHSignature(sig string, payload []byte, allowedSigners ...string) (*VerificationResult, error) {
+	if sig == "" {
+		return nil, fmt.Errorf("unable to verify payload as the provided signature is empty")
+	}
+
+	s, err := parseSSHSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := ssh.ParsePublicKey(s.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse SSH signature public key: %w", err)
+	}
+	if err = verifySSHSignatureWithKey(s, pub, payload); err != nil {
+		return nil, err
+	}
+
+	for _, as := range allowedSigners {
+		signers, err := parseAllowedSigners(as)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read allowed signers: %w", err)
+		}
+		for _, signer := range signers {
+			if !bytes.Equal(signer.PublicKey.Marshal(), pub.Marshal()) {
+				continue
+			}
+			if len(signer.Namespaces) > 0 && !matchPatternList(signer.Namespaces, s.Namespace) {
+				continue
+			}
+			fingerprint := ssh.FingerprintSHA256(pub)
+			return &VerificationResult{
+				Format:      SignatureFormatSSH,
+				KeyID:       fingerprint,
+				Fingerprint: fingerprint,
+				Identity:    strings.Join(signer.Principals, ","),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to verify payload with any of the given allowed signers")
+}
+
+// parseSSHSignature decodes the given armored SSH signature and validates
+// its preamble, version and namespace.
+func parseSSHSignature(sig string) (*sshSignature, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(sig)))
+	if block == nil || block.Type != sshSignaturePEMType {
+		return nil, fmt.Errorf("unable to decode SSH signature: not an armored SSH signature")
+	}
+
+	s := &sshSignature{}
+	if err := ssh.Unmarshal(block.Bytes, s); err != nil {
+		return nil, fmt.Errorf("unable to decode SSH signature: %w", err)
+	}
+	if string(s.Magic[:]) != sshSignatureMagic {
+		return nil, fmt.Errorf("unable to decode SSH signature: invalid magic preamble")
+	}
+	if s.Version != sshSignatureVersion {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", s.Version)
+	}
+	if s.Namespace != sshSignatureNamespace {
+		return nil, fmt.Errorf("invalid SSH signature namespace '%s', expected '%s'", s.Namespace, sshSignatureNamespace)
+	}
+	return s, nil
+}
+
+// verifySSHSignatureWithKey verifies the signature over the payload using
+// the given public key.
+func verifySSHSignatureWithKey(s *sshSignature, pub ssh.PublicKey, payload []byte) error {
+	var h hash.Hash
+	switch s.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported SSH signature hash algorithm '%s'", s.HashAlgorithm)
+	}
+	h.Write(payload)
+
+	signed := sshSignedData{
+		Namespace:     s.Namespace,
+		Reserved:      s.Reserved,
+		HashAlgorithm: s.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	}
+	copy(signed.Magic[:], sshSignatureMagic)
+
+	sshSig := &ssh.Signature{}
+	if err := ssh.Unmarshal(s.Signature, sshSig); err != nil {
+		return fmt.Errorf("unable to decode SSH signature blob: %w", err)
+	}
+	// PROTOCOL.sshsig does not allow the SHA-1 based "ssh-rsa" signature
+	// algorithm, RSA signatures must use "rsa-sha2-256" or "rsa-sha2-512".
+	if sshSig.Format == ssh.KeyAlgoRSA {
+		return fmt.Errorf("unsupported SSH signature algorithm '%s'", sshSig.Format)
+	}
+	if err := pub.Verify(ssh.Marshal(signed), sshSig); err != nil {
+		return fmt.Errorf("unable to verify SSH signature: %w", err)
+	}
+	return nil
+}
+
+// parseAllowedSigners parses the given allowed signers file content.
+// Each line has the format "principals [options] keytype base64-key [comment]".
+// Entries with the "cert-authority", "valid-after" or "valid-before" options
+// are not supported and are skipped.
+func parseAllowedSigners(data string) ([]allowedSigner, error) {
+	var signers []allowedSigner
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		principals, rest, err := cutAllowedSignersField(line)
+		if err != nil {
+			return nil, err
+		}
+		pub, _, options, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse allowed signers entry for '%s': %w", principals, err)
+		}
+
+		signer := allowedSigner{
+			Principals: strings.Split(principals, ","),
+			PublicKey:  pub,
+		}
+		supported := true
+		for _, o := range options {
+			name, value, _ := strings.Cut(o, "=")
+			switch strings.ToLower(name) {
+			case "namespaces":
+				signer.Namespaces = strings.Split(strings.Trim(value, `"`), ",")
+			case "cert-authority", "valid-after", "valid-before":
+				supported = false
+			}
+		}
+		if supported {
+			signers = append(signers, signer)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
+
+// cutAllowedSignersField splits off the (optionally quoted) principals
+// field from an allowed signers line.
+func cutAllowedSignersField(line string) (string, string, error) {
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return "", "", errors.New("unterminated quoted principals")
+		}
+		return line[1 : end+1], strings.TrimSpace(line[end+2:]), nil
+	}
+	principals, rest, found := strings.Cut(line, " ")
+	if !found {
+		return "", "", fmt.Errorf("missing public key for principals '%s'", principals)
+	}
+	return principals, strings.TrimSpace(rest), nil
+}
+
+// matchPatternList reports whether s matches any of the given patterns,
+// which may contain the '*' and '?' wildcards. Negated patterns prefixed
+// with '!' take precedence.
+func matchPatternList(patterns []string, s string) bool {
+	var matched bool
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "!") {
+			if matchPattern(p[1:], s) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(p, s) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern reports whether s matches the pattern, in which '*'
+// matches any sequence of characters and '?' matches a single character.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// ParseSSHUserCertificate parses an OpenSSH user certificate in
+// authorized_keys format, as written to "<key>-cert.pub" by ssh-keygen.
+func ParseSSHUserCertificate(data []byte) (*ssh.Certificate, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse SSH certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse SSH certificate: '%s' key is not a certificate", pub.Type())
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("unable to parse SSH certificate: not a user certificate")
+	}
+	return cert, nil
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/pem"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	encodedSSHCommitFixture = `tree df55a7dce59d040dc7819c1e241082965a80ebd9
+author Flux <flux@example.com> 1700000000 +0000
+committer Flux <flux@example.com> 1700000000 +0000
+
+Initial commit
+`
+
+	signatureSSHCommitFixture = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAg7g74ZTittFE1v52tuRow+/AVqs
+STuzM0D71GoNVK6q4AAAADZ2l0AAAAAAAAAAZzaGE1MTIAAABTAAAAC3NzaC1lZDI1NTE5
+AAAAQPDws5qfjRzq5s2Y+K0OwKOjCJVV9mTvWIp20yTkYe2x+Avo7zqBCDNOXwTZQRGfbM
+X8yFSsOnng1c6jWlJW/A8=
+-----END SSH SIGNATURE-----`
+
+	allowedSignersFixture = `# Flux maintainers
+flux@example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIO4O+GU4rbRRNb+drbkaMPvwFarEk7szNA+9RqDVSuqu flux@example.com
+`
+
+	allowedSignersOtherKeyFixture = `other@example.com ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCgpixnqjtMPjP/N+o6KpCyxPIpYwLntzwUzSFuneCrbNKM5LWcGAccHnF7P7JgJX3HuQEKf/HB6C+vy0iniFSSXVSoQEgqDb3JaS5FxRNuBlVlzGvU2aOtrX+p+7WowuTYE4FBwOG2t1887+m329pzF4VwgCt2zorKoMaCZf6vIppNUpvszS67ZUakpCdHsOW+Gf4ubhMTfFn4BP88dipat04l4USewK2P3LgSISR+YUPieIW1mquMqPZSbYhSxeItSmxO9zamtGt4FrRFj+at6bN0LNWFc+mgGSEQSx8hNPruNX7U/G4qmmQjUh8Oklng+wrtFWq77lGLt4c9Xh6v other
+`
+
+	sshFingerprintFixture = "SHA256:yqSUDNTgH6mrrIgj1k9ofhpsH2HEDGfzttBbssJCYiM"
+)
+
+func Test_verifySSHSignature(t *testing.T) {
+	tests := []struct {
+		name           string
+		payload        []byte
+		sig            string
+		allowedSigners []string
+		want           string
+		wantErr        string
+	}{
+		{
+			name:           "Valid commit signature",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{allowedSignersFixture},
+			want:           sshFingerprintFixture,
+		},
+		{
+			name:           "Valid commit signature with multiple allowed signers",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{allowedSignersOtherKeyFixture, allowedSignersFixture},
+			want:           sshFingerprintFixture,
+		},
+		{
+			name:           "Valid commit signature with git namespace",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{`"flux@example.com" namespaces="file,git" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIO4O+GU4rbRRNb+drbkaMPvwFarEk7szNA+9RqDVSuqu`},
+			want:           sshFingerprintFixture,
+		},
+		{
+			name:           "Allowed signer restricted to other namespace",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{`flux@example.com namespaces="file" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIO4O+GU4rbRRNb+drbkaMPvwFarEk7szNA+9RqDVSuqu`},
+			wantErr:        "unable to verify payload with any of the given allowed signers",
+		},
+		{
+			name:           "Unsupported allowed signer option",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{`flux@example.com valid-before="20200101" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIO4O+GU4rbRRNb+drbkaMPvwFarEk7szNA+9RqDVSuqu`},
+			wantErr:        "unable to verify payload with any of the given allowed signers",
+		},
+		{
+			name:           "Unknown signer",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{allowedSignersOtherKeyFixture},
+			wantErr:        "unable to verify payload with any of the given allowed signers",
+		},
+		{
+			name:           "Malformed encoded commit",
+			payload:        []byte(malformedEncodedCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{allowedSignersFixture},
+			wantErr:        "unable to verify SSH signature: ssh: signature did not verify",
+		},
+		{
+			name:           "Malformed allowed signers",
+			payload:        []byte(encodedSSHCommitFixture),
+			sig:            signatureSSHCommitFixture,
+			allowedSigners: []string{"flux@example.com"},
+			wantErr:        "unable to read allowed signers: missing public key for principals 'flux@example.com'",
+		},
+		{
+			name:           "OpenPGP signature",
+			payload:        []byte(encodedCommitFixture),
+			sig:            signatureCommitFixture,
+			allowedSigners: []string{allowedSignersFixture},
+			wantErr:        "unable to decode SSH signature: not an armored SSH signature",
+		},
+		{
+			name:           "Missing signature",
+			payload:        []byte(encodedSSHCommitFixture),
+			allowedSigners: []string{allowedSignersFixture},
+			wantErr:        "unable to verify payload as the provided signature is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := verifySSHSignature(tt.sig, tt.payload, tt.allowedSigners...)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.Format).To(Equal(SignatureFormatSSH))
+			g.Expect(got.Fingerprint).To(Equal(tt.want))
+		})
+	}
+}
+
+func Test_verifySSHSignature_rsaAlgorithm(t *testing.T) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	signer, err := ssh.NewSignerFromKey(key)
+	g.Expect(err).ToNot(HaveOccurred())
+	allowedSigners := "flux@example.com " + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	tests := []struct {
+		algorithm string
+		wantErr   string
+	}{
+		{algorithm: ssh.KeyAlgoRSASHA256},
+		{algorithm: ssh.KeyAlgoRSASHA512},
+		{algorithm: ssh.KeyAlgoRSA, wantErr: "unsupported SSH signature algorithm 'ssh-rsa'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			g := NewWithT(t)
+
+			payload := []byte(encodedSSHCommitFixture)
+			h := sha512.Sum512(payload)
+			signed := sshSignedData{Namespace: sshSignatureNamespace, HashAlgorithm: "sha512", Hash: h[:]}
+			copy(signed.Magic[:], sshSignatureMagic)
+			sig, err := signer.(ssh.AlgorithmSigner).SignWithAlgorithm(rand.Reader, ssh.Marshal(signed), tt.algorithm)
+			g.Expect(err).ToNot(HaveOccurred())
+			s := sshSignature{
+				Version:       sshSignatureVersion,
+				PublicKey:     signer.PublicKey().Marshal(),
+				Namespace:     sshSignatureNamespace,
+				HashAlgorithm: "sha512",
+				Signature:     ssh.Marshal(sig),
+			}
+			copy(s.Magic[:], sshSignatureMagic)
+			armored := pem.EncodeToMemory(&pem.Block{Type: sshSignaturePEMType, Bytes: ssh.Marshal(s)})
+
+			got, err := verifySSHSignature(string(armored), payload, allowedSigners)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.Fingerprint).To(Equal(ssh.FingerprintSHA256(signer.PublicKey())))
+		})
+	}
+}
+
+func TestIsSSHSignature(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsSSHSignature(signatureSSHCommitFixture)).To(BeTrue())
+	g.Expect(IsSSHSignature(signatureCommitFixture)).To(BeFalse())
+	g.Expect(IsSSHSignature("")).To(BeFalse())
+}
+
+func Test_matchPatternList(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		s        string
+		want     bool
+	}{
+		{patterns: []string{"git"}, s: "git", want: true},
+		{patterns: []string{"file", "g?t"}, s: "git", want: true},
+		{patterns: []string{"*"}, s: "git", want: true},
+		{patterns: []string{"*", "!git"}, s: "git", want: false},
+		{patterns: []string{"file"}, s: "git", want: false},
+		{patterns: []string{"*@example.com"}, s: "flux@example.com", want: true},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(matchPatternList(tt.patterns, tt.s)).To(Equal(tt.want), "patterns %v, value %q", tt.patterns, tt.s)
+	}
+}
+
+func TestParseSSHUserCertificate(t *testing.T) {
+	g := NewWithT(t)
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	ca, err := ssh.NewSignerFromKey(caKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).ToNot(HaveOccurred())
+	sshPub, err := ssh.NewPublicKey(pub)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	newCert := func(certType uint32) []byte {
+		cert := &ssh.Certificate{
+			Key:             sshPub,
+			CertType:        certType,
+			KeyId:           "flux",
+			ValidPrincipals: []string{"git"},
+			ValidBefore:     ssh.CertTimeInfinity,
+		}
+		g.Expect(cert.SignCert(rand.Reader, ca)).To(Succeed())
+		return ssh.MarshalAuthorizedKey(cert)
+	}
+
+	cert, err := ParseSSHUserCertificate(newCert(ssh.UserCert))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cert.KeyId).To(Equal("flux"))
+
+	_, err = ParseSSHUserCertificate(newCert(ssh.HostCert))
+	g.Expect(err).To(MatchError("unable to parse SSH certificate: not a user certificate"))
+
+	_, err = ParseSSHUserCertificate(ssh.MarshalAuthorizedKey(sshPub))
+	g.Expect(err).To(MatchError("unable to parse SSH certificate: 'ssh-ed25519' key is not a certificate"))
+
+	_, err = ParseSSHUserCertificate([]byte("invalid"))
+	g.Expect(err).To(HaveOccurred())
+}
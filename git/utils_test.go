@@ -87,6 +87,16 @@ func TestTransformRevision(t *testing.T) {
 			rev:  "HEAD/5394cb7f48332b2de7c17dd8b8384bbc84b7e738",
 			want: "sha1:5394cb7f48332b2de7c17dd8b8384bbc84b7e738",
 		},
+		{
+			name: "legacy revision with branch and SHA-256 hash",
+			rev:  "main/6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+			want: "main@sha256:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+		},
+		{
+			name: "revision with branch and SHA-256 digest",
+			rev:  "main@sha256:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+			want: "main@sha256:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+		},
 		{
 			name: "empty revision",
 			rev:  "",
@@ -144,6 +154,12 @@ func TestSplitRevision(t *testing.T) {
 			rev:      "5394cb7f48332b2de7c17dd8b8384bbc84b7e738",
 			wantHash: Hash("5394cb7f48332b2de7c17dd8b8384bbc84b7e738"),
 		},
+		{
+			name:        "revision with branch and SHA-256 digest",
+			rev:         "main@sha256:6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8",
+			wantPointer: "main",
+			wantHash:    Hash("6ee9a7ade2ca791bc1bf9d133ef6ddaa9097cf521e6a19be92dbcc3f2e82f6d8"),
+		},
 		{
 			name:        "empty revision",
 			rev:         "",
@@ -0,0 +1,204 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// SignatureFormat is the format of a Git commit or tag signature.
+type SignatureFormat string
+
+const (
+	// SignatureFormatOpenPGP is an OpenPGP signature.
+	SignatureFormatOpenPGP SignatureFormat = "openpgp"
+	// SignatureFormatSSH is an SSH signature.
+	SignatureFormatSSH SignatureFormat = "ssh"
+	// SignatureFormatX509 is an X.509 (CMS) signature.
+	SignatureFormatX509 SignatureFormat = "x509"
+	// SignatureFormatUnknown is an unknown signature format.
+	SignatureFormatUnknown SignatureFormat = "<unknown>"
+)
+
+var (
+	// ErrKeyExpired indicates that the signature is valid, but the key it
+	// was made with has expired.
+	ErrKeyExpired = errors.New("signing key has expired")
+	// ErrKeyRevoked indicates that the signature is valid, but the key it
+	// was made with has been revoked.
+	ErrKeyRevoked = errors.New("signing key has been revoked")
+)
+
+// DetectSignatureFormat returns the format of the given armored signature.
+func DetectSignatureFormat(sig string) SignatureFormat {
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(sig), "-----BEGIN PGP SIGNATURE-----"),
+		strings.HasPrefix(strings.TrimSpace(sig), "-----BEGIN PGP MESSAGE-----"):
+		return SignatureFormatOpenPGP
+	case IsSSHSignature(sig):
+		return SignatureFormatSSH
+	case IsX509Signature(sig):
+		return SignatureFormatX509
+	default:
+		return SignatureFormatUnknown
+	}
+}
+
+// VerifyOptions holds the trust material used to verify a signature. Only
+// the material matching the format of the signature is used.
+type VerifyOptions struct {
+	// KeyRings is a list of armored OpenPGP key rings.
+	KeyRings []string
+	// AllowedSigners is a list of SSH allowed signers, in the format
+	// described in the "ALLOWED SIGNERS" section of ssh-keygen(1).
+	AllowedSigners []string
+	// X509 holds the options to verify X.509 (CMS) signatures.
+	X509 X509VerifyOptions
+}
+
+// VerificationResult describes a verified signature.
+type VerificationResult struct {
+	// Format is the format of the signature.
+	Format SignatureFormat
+	// KeyID is the ID of the key the signature was verified with. For
+	// OpenPGP this is the (long) key ID of the primary key, for SSH the
+	// SHA256 fingerprint of the key and for X.509 the subject key ID (or
+	// serial number) of the signing certificate.
+	KeyID string
+	// Fingerprint is the full fingerprint of the key the signature was
+	// verified with.
+	Fingerprint string
+	// Identity is the identity bound to the key. For OpenPGP this is the
+	// primary user ID, for SSH the principals of the allowed signers entry
+	// and for X.509 the subject of the signing certificate.
+	Identity string
+	// SignedAt is the creation time of the signature, if available.
+	// SSH signatures do not carry a creation time.
+	SignedAt time.Time
+	// KeyExpired indicates the key has expired. For X.509 signatures, the
+	// certificate chain is verified at the time of signing, and this only
+	// reports whether the certificate has expired since.
+	KeyExpired bool
+	// KeyRevoked indicates the key has been revoked.
+	KeyRevoked bool
+}
+
+// VerifySignature verifies the Signature of the commit using the trust
+// material for its format from the given options. It returns a result
+// describing the verified signature, or an error.
+// If the signature is valid but was made with an expired or revoked OpenPGP
+// key, both the result and an error wrapping ErrKeyExpired or ErrKeyRevoked
+// are returned.
+// Like Verify, it does not verify the signature of the referencing tag
+// (if present).
+func (c *Commit) VerifySignature(opts VerifyOptions) (*VerificationResult, error) {
+	result, err := verifySignatureWithOptions(c.Signature, c.Encoded, opts)
+	if err != nil {
+		return result, fmt.Errorf("unable to verify Git commit: %w", err)
+	}
+	return result, nil
+}
+
+// VerifySignature verifies the Signature of the tag using the trust
+// material for its format from the given options. It returns a result
+// describing the verified signature, or an error.
+// If the signature is valid but was made with an expired or revoked OpenPGP
+// key, both the result and an error wrapping ErrKeyExpired or ErrKeyRevoked
+// are returned.
+func (t *Tag) VerifySignature(opts VerifyOptions) (*VerificationResult, error) {
+	result, err := verifySignatureWithOptions(t.Signature, t.Encoded, opts)
+	if err != nil {
+		return result, fmt.Errorf("unable to verify Git tag: %w", err)
+	}
+	return result, nil
+}
+
+func verifySignatureWithOptions(sig string, payload []byte, opts VerifyOptions) (*VerificationResult, error) {
+	if sig == "" {
+		return nil, fmt.Errorf("unable to verify payload as the provided signature is empty")
+	}
+
+	switch format := DetectSignatureFormat(sig); format {
+	case SignatureFormatOpenPGP:
+		return verifyOpenPGPSignature(sig, payload, opts.KeyRings...)
+	case SignatureFormatSSH:
+		return verifySSHSignature(sig, payload, opts.AllowedSigners...)
+	case SignatureFormatX509:
+		return verifyX509Signature(sig, payload, opts.X509)
+	default:
+		return nil, fmt.Errorf("unable to verify payload with signature of unknown format")
+	}
+}
+
+func verifyOpenPGPSignature(sig string, payload []byte, keyRings ...string) (*VerificationResult, error) {
+	if sig == "" {
+		return nil, fmt.Errorf("unable to verify payload as the provided signature is empty")
+	}
+
+	var (
+		result    *VerificationResult
+		resultErr error
+	)
+	for _, r := range keyRings {
+		keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(r))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read armored key ring: %w", err)
+		}
+		block, err := armor.Decode(strings.NewReader(sig))
+		if err != nil || block.Type != openpgp.SignatureType {
+			continue
+		}
+
+		s, signer, err := openpgp.VerifyDetachedSignature(keyring, bytes.NewBuffer(payload), block.Body, nil)
+		if signer == nil || s == nil {
+			continue
+		}
+
+		r := &VerificationResult{
+			Format:      SignatureFormatOpenPGP,
+			KeyID:       signer.PrimaryKey.KeyIdString(),
+			Fingerprint: fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint),
+			SignedAt:    s.CreationTime,
+		}
+		if id := signer.PrimaryIdentity(); id != nil {
+			r.Identity = id.Name
+		}
+
+		switch {
+		case err == nil:
+			return r, nil
+		case errors.Is(err, pgperrors.ErrKeyRevoked):
+			r.KeyRevoked = true
+			result, resultErr = r, ErrKeyRevoked
+		case errors.Is(err, pgperrors.ErrKeyExpired):
+			r.KeyExpired = true
+			result, resultErr = r, ErrKeyExpired
+		}
+	}
+	if result != nil {
+		return result, resultErr
+	}
+	return nil, fmt.Errorf("unable to verify payload with any of the given key rings")
+}
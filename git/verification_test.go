@@ -0,0 +1,247 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// expiredKeyRingFixture is an Ed25519 key which expired one day after
+	// its creation.
+	expiredKeyRingFixture = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEXgvhABYJKwYBBAHaRw8BAQdAWxyRL7KZPdU6vZaHhJ8W8LXw7MaZ5C6Xd4a5
+lFBqvxO0IkV4cGlyZWQgRmx1eCA8ZXhwaXJlZEBleGFtcGxlLmNvbT6IlgQTFggA
+PhYhBPzoG5fQACvrIjgEF+maqvnmJ4TqBQJeC+EAAhsDBQkAAVGABQsJCAcCBhUK
+CQgLAgQWAgMBAh4BAheAAAoJEOmaqvnmJ4TqPLUBAPn1CigqipF0U1V7k+5l+NZK
+irY7AqJ/Vcgfn8pPqWx6AQC0GIuD/aayL/6BS1nd1MiZIcOKLxdfaeYqOU2W26Gm
+DQ==
+=VXZn
+-----END PGP PUBLIC KEY BLOCK-----`
+
+	// signatureExpiredKeyFixture is a signature over encodedSSHCommitFixture,
+	// made with expiredKeyRingFixture after the key expired.
+	signatureExpiredKeyFixture = `-----BEGIN PGP SIGNATURE-----
+
+iIkEABYIADIWIQT86BuX0AAr6yI4BBfpmqr55ieE6gUCXgv9IBQcZXhwaXJlZEBl
+eGFtcGxlLmNvbQAKCRDpmqr55ieE6j+vAPwOzVC6aDp3+wt30V38hDsT6J3yCnyr
+ZXh0zi7+uT4ONAD4o/RRXnOCWL1oMHG/Z7KWskaOs/veQxppvoBDLbskDg==
+=Zo0q
+-----END PGP SIGNATURE-----`
+
+	// revokedKeyRingFixture is an Ed25519 key with a revocation signature.
+	revokedKeyRingFixture = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEXgvvEBYJKwYBBAHaRw8BAQdABFZmC8nqCfZpbT7roJfZbgxZbkG3kDdRm2qb
+UkrPuR+IeAQgFggAIBYhBCN5x1NCtFS0R4pSCZvVuw/VxK8JBQJeC+8QAh0AAAoJ
+EJvVuw/VxK8JxmoBAMDC6n96l0r6YIUBlEDviWQidv2p5DdGfQFX5NgyVlA4APwM
+lVqVmpmiajk3XqKKM8z/pcDhm1V2LxiZc+HRbS+bArQiUmV2b2tlZCBGbHV4IDxy
+ZXZva2VkQGV4YW1wbGUuY29tPoiQBBMWCAA4FiEEI3nHU0K0VLRHilIJm9W7D9XE
+rwkFAl4L7xACGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AACgkQm9W7D9XErwnC
+oQEAkzTTQ4ve967UGa5bppThaJbYp2twT7zVdp17DAdsccQA/3l+FqnR3NkXtBnT
+Pf0xkw2NR/Y3VmbiDnQyjevu4aIN
+=oDhk
+-----END PGP PUBLIC KEY BLOCK-----`
+
+	// signatureRevokedKeyFixture is a signature over encodedSSHCommitFixture,
+	// made with revokedKeyRingFixture.
+	signatureRevokedKeyFixture = `-----BEGIN PGP SIGNATURE-----
+
+iIoEABYIADIWIQQjecdTQrRUtEeKUgmb1bsP1cSvCQUCXgv9IBQccmV2b2tlZEBl
+eGFtcGxlLmNvbQAKCRCb1bsP1cSvCWCJAP9N63Tcv1xhlTAExlJUwpmMhh/djgPz
+ZIesgBdGv6hdeAEAup9Y+x2tuQDZynXx27ZBQ0wVmB28Y98m7XY9qCbCOwg=
+=2+ss
+-----END PGP SIGNATURE-----`
+)
+
+func TestCommit_VerifySignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		commit  Commit
+		opts    VerifyOptions
+		want    *VerificationResult
+		wantErr error
+		errMsg  string
+	}{
+		{
+			name: "OpenPGP signature",
+			commit: Commit{
+				Encoded:   []byte(encodedCommitFixture),
+				Signature: signatureCommitFixture,
+			},
+			opts: VerifyOptions{KeyRings: []string{armoredKeyRingFixture}},
+			want: &VerificationResult{
+				Format:      SignatureFormatOpenPGP,
+				KeyID:       keyRingFingerprintFixture,
+				Fingerprint: "07804C54AF816B2DD2B3A4D63299AEB0E4085BAF",
+				Identity:    "Stefan Prodan <stefan.prodan@gmail.com>",
+				SignedAt:    time.Date(2021, 10, 8, 8, 22, 44, 0, time.UTC),
+			},
+		},
+		{
+			name: "OpenPGP signature with expired key",
+			commit: Commit{
+				Encoded:   []byte(encodedSSHCommitFixture),
+				Signature: signatureExpiredKeyFixture,
+			},
+			opts: VerifyOptions{KeyRings: []string{expiredKeyRingFixture}},
+			want: &VerificationResult{
+				Format:      SignatureFormatOpenPGP,
+				KeyID:       "E99AAAF9E62784EA",
+				Fingerprint: "FCE81B97D0002BEB22380417E99AAAF9E62784EA",
+				Identity:    "Expired Flux <expired@example.com>",
+				SignedAt:    time.Date(2020, 1, 1, 2, 0, 0, 0, time.UTC),
+				KeyExpired:  true,
+			},
+			wantErr: ErrKeyExpired,
+		},
+		{
+			name: "OpenPGP signature with revoked key",
+			commit: Commit{
+				Encoded:   []byte(encodedSSHCommitFixture),
+				Signature: signatureRevokedKeyFixture,
+			},
+			opts: VerifyOptions{KeyRings: []string{revokedKeyRingFixture}},
+			want: &VerificationResult{
+				Format:      SignatureFormatOpenPGP,
+				KeyID:       "9BD5BB0FD5C4AF09",
+				Fingerprint: "2379C75342B454B4478A52099BD5BB0FD5C4AF09",
+				Identity:    "Revoked Flux <revoked@example.com>",
+				SignedAt:    time.Date(2020, 1, 1, 2, 0, 0, 0, time.UTC),
+				KeyRevoked:  true,
+			},
+			wantErr: ErrKeyRevoked,
+		},
+		{
+			name: "OpenPGP signature with unknown key",
+			commit: Commit{
+				Encoded:   []byte(encodedCommitFixture),
+				Signature: signatureCommitFixture,
+			},
+			opts:   VerifyOptions{KeyRings: []string{expiredKeyRingFixture}},
+			errMsg: "unable to verify Git commit: unable to verify payload with any of the given key rings",
+		},
+		{
+			name: "SSH signature",
+			commit: Commit{
+				Encoded:   []byte(encodedSSHCommitFixture),
+				Signature: signatureSSHCommitFixture,
+			},
+			opts: VerifyOptions{
+				KeyRings:       []string{armoredKeyRingFixture},
+				AllowedSigners: []string{allowedSignersFixture},
+			},
+			want: &VerificationResult{
+				Format:      SignatureFormatSSH,
+				KeyID:       sshFingerprintFixture,
+				Fingerprint: sshFingerprintFixture,
+				Identity:    "flux@example.com",
+			},
+		},
+		{
+			name: "SSH signature without allowed signers",
+			commit: Commit{
+				Encoded:   []byte(encodedSSHCommitFixture),
+				Signature: signatureSSHCommitFixture,
+			},
+			opts:   VerifyOptions{KeyRings: []string{armoredKeyRingFixture}},
+			errMsg: "unable to verify Git commit: unable to verify payload with any of the given allowed signers",
+		},
+		{
+			name: "Unknown signature format",
+			commit: Commit{
+				Encoded:   []byte(encodedCommitFixture),
+				Signature: "invalid",
+			},
+			errMsg: "unable to verify Git commit: unable to verify payload with signature of unknown format",
+		},
+		{
+			name: "Missing signature",
+			commit: Commit{
+				Encoded: []byte(encodedCommitFixture),
+			},
+			errMsg: "unable to verify Git commit: unable to verify payload as the provided signature is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := tt.commit.VerifySignature(tt.opts)
+			switch {
+			case tt.wantErr != nil:
+				g.Expect(err).To(MatchError(tt.wantErr))
+			case tt.errMsg != "":
+				g.Expect(err).To(MatchError(tt.errMsg))
+			default:
+				g.Expect(err).ToNot(HaveOccurred())
+			}
+			if tt.want == nil {
+				g.Expect(got).To(BeNil())
+				return
+			}
+
+			g.Expect(got).ToNot(BeNil())
+			g.Expect(got.SignedAt).To(BeTemporally("==", tt.want.SignedAt))
+			got.SignedAt = tt.want.SignedAt
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestTag_VerifySignature(t *testing.T) {
+	g := NewWithT(t)
+
+	tag := Tag{
+		Encoded:   []byte(encodedX509CommitFixture),
+		Signature: signatureX509CommitFixture,
+	}
+	got, err := tag.VerifySignature(VerifyOptions{
+		X509: X509VerifyOptions{RootCAs: []byte(rootCAFixture)},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(got.Format).To(Equal(SignatureFormatX509))
+	g.Expect(got.Identity).To(Equal(x509SubjectFixture))
+
+	got, err = tag.VerifySignature(VerifyOptions{
+		X509: X509VerifyOptions{RootCAs: []byte(otherRootCAFixture)},
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(HavePrefix("unable to verify Git tag: "))
+	g.Expect(got).To(BeNil())
+}
+
+func TestDetectSignatureFormat(t *testing.T) {
+	tests := []struct {
+		sig  string
+		want SignatureFormat
+	}{
+		{sig: signatureCommitFixture, want: SignatureFormatOpenPGP},
+		{sig: signatureSSHCommitFixture, want: SignatureFormatSSH},
+		{sig: signatureX509CommitFixture, want: SignatureFormatX509},
+		{sig: "", want: SignatureFormatUnknown},
+		{sig: "invalid", want: SignatureFormatUnknown},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(DetectSignatureFormat(tt.sig)).To(Equal(tt.want))
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+)
+
+// x509SignaturePEMType is the PEM block type of an armored X.509 (CMS)
+// signature, as created by Git when 'gpg.format' is set to 'x509'.
+const x509SignaturePEMType = "SIGNED MESSAGE"
+
+// X509VerifyOptions holds the options used to verify X.509 (S/MIME)
+// signatures, as produced by e.g. gitsign or smimesign.
+type X509VerifyOptions struct {
+	// RootCAs is a PEM encoded bundle of trusted CA certificates. The
+	// certificate chain of the signer is verified against it at the
+	// signing time of the signature.
+	RootCAs []byte
+
+	// Identities optionally restricts the accepted signers. An identity
+	// matches the email address and URI SANs, and the subject common name
+	// of the signing certificate. It may contain '*' and '?' wildcards.
+	Identities []string
+
+	// Issuers optionally restricts the accepted issuers of the signing
+	// certificate. An issuer matches the distinguished name or the common
+	// name of the issuer. It may contain '*' and '?' wildcards.
+	Issuers []string
+}
+
+// IsX509Signature returns true if the provided signature is an armored
+// X.509 (CMS) signature.
+func IsX509Signature(sig string) bool {
+	return strings.HasPrefix(strings.TrimSpace(sig), "-----BEGIN "+x509SignaturePEMType+"-----")
+}
+
+func verifyX509Signature(sig string, payload []byte, opts X509VerifyOptions) (*VerificationResult, error) {
+	if sig == "" {
+		return nil, fmt.Errorf("unable to verify payload as the provided signature is empty")
+	}
+	if len(opts.RootCAs) == 0 {
+		return nil, errors.New("unable to verify payload without any trusted CA certificates")
+	}
+
+	block, _ := pem.Decode([]byte(strings.TrimSpace(sig)))
+	if block == nil || block.Type != x509SignaturePEMType {
+		return nil, fmt.Errorf("unable to decode X.509 signature: not an armored CMS signature")
+	}
+	p7, err := pkcs7.Parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode X.509 signature: %w", err)
+	}
+	cert := p7.GetOnlySigner()
+	if cert == nil {
+		return nil, fmt.Errorf("unable to verify X.509 signature: expected exactly one signer")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(opts.RootCAs) {
+		return nil, fmt.Errorf("unable to read trusted CA certificates")
+	}
+
+	// The signature is detached, the payload is therefore the content.
+	p7.Content = payload
+	if err = p7.VerifyWithChain(roots); err != nil {
+		return nil, fmt.Errorf("unable to verify X.509 signature: %w", err)
+	}
+
+	if len(opts.Identities) > 0 && !matchCertificateIdentity(cert, opts.Identities) {
+		return nil, fmt.Errorf("signer '%s' does not match any of the given identities", cert.Subject.String())
+	}
+	if len(opts.Issuers) > 0 && !matchCertificateIssuer(cert, opts.Issuers) {
+		return nil, fmt.Errorf("issuer '%s' does not match any of the given issuers", cert.Issuer.String())
+	}
+
+	result := &VerificationResult{
+		Format:      SignatureFormatX509,
+		KeyID:       hex.EncodeToString(cert.SubjectKeyId),
+		Fingerprint: fmt.Sprintf("%X", sha256.Sum256(cert.Raw)),
+		Identity:    cert.Subject.String(),
+		KeyExpired:  time.Now().After(cert.NotAfter),
+	}
+	if len(cert.SubjectKeyId) == 0 {
+		result.KeyID = cert.SerialNumber.Text(16)
+	}
+	var signingTime time.Time
+	if err = p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime); err == nil {
+		result.SignedAt = signingTime
+	}
+	return result, nil
+}
+
+// matchCertificateIdentity reports whether any of the identities of the
+// certificate matches one of the given patterns.
+func matchCertificateIdentity(cert *x509.Certificate, patterns []string) bool {
+	identities := append([]string{}, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		identities = append(identities, u.String())
+	}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	return matchAnyPattern(patterns, identities...)
+}
+
+// matchCertificateIssuer reports whether the issuer of the certificate
+// matches one of the given patterns.
+func matchCertificateIssuer(cert *x509.Certificate, patterns []string) bool {
+	issuers := []string{cert.Issuer.String()}
+	if cert.Issuer.CommonName != "" {
+		issuers = append(issuers, cert.Issuer.CommonName)
+	}
+	return matchAnyPattern(patterns, issuers...)
+}
+
+// matchAnyPattern reports whether any of the values matches one of the
+// patterns.
+func matchAnyPattern(patterns []string, values ...string) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if matchPattern(p, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
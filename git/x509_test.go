@@ -0,0 +1,195 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// encodedX509CommitFixture is the same commit as encodedSSHCommitFixture,
+	// signed using an X.509 certificate instead.
+	encodedX509CommitFixture = encodedSSHCommitFixture
+
+	signatureX509CommitFixture = `-----BEGIN SIGNED MESSAGE-----
+MIIDoQYJKoZIhvcNAQcCoIIDkjCCA44CAQExDTALBglghkgBZQMEAgEwCwYJKoZI
+hvcNAQcBoIIB1jCCAdIwggF4oAMCAQICFBkilDt8JBkcb5rNLMD1pt+qF4XPMAoG
+CCqGSM49BAMCMCYxDTALBgNVBAoMBEZsdXgxFTATBgNVBAMMDEZsdXggVGVzdCBD
+QTAgFw0yNjEwMTcwMTI3NTRaGA8yMTI2MDkyMzAxMjc1NFowIjENMAsGA1UECgwE
+Rmx1eDERMA8GA1UEAwwIRmx1eCBCb3QwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNC
+AAS3l6/hrHU/Rg8raddSwvlXT5zU3XkA8eEK/8hANpRMBsZOuk21xfxgYcgnQn7p
+EtjdQjdOginvzaL68RL/dG50o4GFMIGCMBsGA1UdEQQUMBKBEGZsdXhAZXhhbXBs
+ZS5jb20wDgYDVR0PAQH/BAQDAgeAMBMGA1UdJQQMMAoGCCsGAQUFBwMDMB0GA1Ud
+DgQWBBTRbZHKTBEsa7LvZDaC/KG9/0995jAfBgNVHSMEGDAWgBTXlFlZ1/nS4LwJ
+099wmM5lh+sHUzAKBggqhkjOPQQDAgNIADBFAiBtFrOfF4NBJwu14U30YdTw7RRo
+3mvcqngqEeTLpPTpJgIhANET7pqKSoIM8darpFOzwIGBykNJfm2YuvGMlJnE4X2b
+MYIBkTCCAY0CAQEwPjAmMQ0wCwYDVQQKDARGbHV4MRUwEwYDVQQDDAxGbHV4IFRl
+c3QgQ0ECFBkilDt8JBkcb5rNLMD1pt+qF4XPMAsGCWCGSAFlAwQCAaCB5DAYBgkq
+hkiG9w0BCQMxCwYJKoZIhvcNAQcBMBwGCSqGSIb3DQEJBTEPFw0yNjEwMTcwMTI3
+NTRaMC8GCSqGSIb3DQEJBDEiBCA/9+XrBzstoZXrJvaWyey91E6DMrzGPOH/Ft1w
+RtsTBjB5BgkqhkiG9w0BCQ8xbDBqMAsGCWCGSAFlAwQBKjALBglghkgBZQMEARYw
+CwYJYIZIAWUDBAECMAoGCCqGSIb3DQMHMA4GCCqGSIb3DQMCAgIAgDANBggqhkiG
+9w0DAgIBQDAHBgUrDgMCBzANBggqhkiG9w0DAgIBKDAKBggqhkjOPQQDAgRIMEYC
+IQDWpQAzFuAJjpuDvMLngvEm9dzzYlJpu+VZCKcXEkJHmQIhANurOZogkNyeaAm9
+8gP+Tw3ZpYe49/tPYRWn+LYXorko
+-----END SIGNED MESSAGE-----`
+
+	rootCAFixture = `-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIULPx3JyIJsdzyPMJU/nQvfYScgL8wCgYIKoZIzj0EAwIw
+JjENMAsGA1UECgwERmx1eDEVMBMGA1UEAwwMRmx1eCBUZXN0IENBMCAXDTI2MTAx
+NzAxMjc1NFoYDzIxMjYwOTIzMDEyNzU0WjAmMQ0wCwYDVQQKDARGbHV4MRUwEwYD
+VQQDDAxGbHV4IFRlc3QgQ0EwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAASAnBwO
+KnMU65xUe/hjWSfiVa4goC9aNdPrDQ8ScmoTbb59fgSFhjwqv7DPCtOdvs9Az+q7
+W7h7aGk02dqrdSuRo2MwYTAdBgNVHQ4EFgQU15RZWdf50uC8CdPfcJjOZYfrB1Mw
+HwYDVR0jBBgwFoAU15RZWdf50uC8CdPfcJjOZYfrB1MwDwYDVR0TAQH/BAUwAwEB
+/zAOBgNVHQ8BAf8EBAMCAgQwCgYIKoZIzj0EAwIDSAAwRQIgcqIrSrQWDcyzbFSp
+wFYzGkzdJV4nx/O+HSuewOp+BLwCIQCe0ris7XCc2zaMLb0GfoS8iPnVLziPVym7
+0PVMm8X5Qw==
+-----END CERTIFICATE-----`
+
+	otherRootCAFixture = `-----BEGIN CERTIFICATE-----
+MIIBfjCCASOgAwIBAgIUHIMvIZkkWSP2cGHRnUpb3gS0Ug8wCgYIKoZIzj0EAwIw
+EzERMA8GA1UEAwwIT3RoZXIgQ0EwIBcNMjYxMDE3MDEyNzU0WhgPMjEyNjA5MjMw
+MTI3NTRaMBMxETAPBgNVBAMMCE90aGVyIENBMFkwEwYHKoZIzj0CAQYIKoZIzj0D
+AQcDQgAEG2ChW8eotM7wNRm+FlRAnVkuReTyHGo549PrJAMtY0Ne+imuoPvSrwf+
+bacfVhn7ENuVtRUxBfyAt+2jZDAWKqNTMFEwHQYDVR0OBBYEFEC+M4XGfUKjkUoo
+V5MKEAGnbA74MB8GA1UdIwQYMBaAFEC+M4XGfUKjkUooV5MKEAGnbA74MA8GA1Ud
+EwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSQAwRgIhAPbPfAdY3crj/fRMQ+gcHuNP
+a/XL+P/Wy7sAQvFRyr+NAiEA7B9RT2qveb+LHkJ2S8GwCbdvvovnlGxVwWq53TUx
+P4o=
+-----END CERTIFICATE-----`
+
+	x509SubjectFixture = "CN=Flux Bot,O=Flux"
+)
+
+func Test_verifyX509Signature(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		sig     string
+		opts    X509VerifyOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "Valid commit signature",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts:    X509VerifyOptions{RootCAs: []byte(rootCAFixture)},
+			want:    x509SubjectFixture,
+		},
+		{
+			name:    "Valid commit signature with CA bundle",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts:    X509VerifyOptions{RootCAs: []byte(otherRootCAFixture + "\n" + rootCAFixture)},
+			want:    x509SubjectFixture,
+		},
+		{
+			name:    "Valid commit signature with identity and issuer constraints",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts: X509VerifyOptions{
+				RootCAs:    []byte(rootCAFixture),
+				Identities: []string{"*@example.com"},
+				Issuers:    []string{"Flux Test CA"},
+			},
+			want: x509SubjectFixture,
+		},
+		{
+			name:    "Identity mismatch",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts: X509VerifyOptions{
+				RootCAs:    []byte(rootCAFixture),
+				Identities: []string{"bot@example.org"},
+			},
+			wantErr: "signer 'CN=Flux Bot,O=Flux' does not match any of the given identities",
+		},
+		{
+			name:    "Issuer mismatch",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts: X509VerifyOptions{
+				RootCAs: []byte(rootCAFixture),
+				Issuers: []string{"CN=Other CA"},
+			},
+			wantErr: "issuer 'CN=Flux Test CA,O=Flux' does not match any of the given issuers",
+		},
+		{
+			name:    "Untrusted CA",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts:    X509VerifyOptions{RootCAs: []byte(otherRootCAFixture)},
+			wantErr: "failed to verify certificate chain",
+		},
+		{
+			name:    "Malformed encoded commit",
+			payload: []byte(malformedEncodedCommitFixture),
+			sig:     signatureX509CommitFixture,
+			opts:    X509VerifyOptions{RootCAs: []byte(rootCAFixture)},
+			wantErr: "unable to verify X.509 signature",
+		},
+		{
+			name:    "Missing CA certificates",
+			payload: []byte(encodedX509CommitFixture),
+			sig:     signatureX509CommitFixture,
+			wantErr: "unable to verify payload without any trusted CA certificates",
+		},
+		{
+			name:    "SSH signature",
+			payload: []byte(encodedSSHCommitFixture),
+			sig:     signatureSSHCommitFixture,
+			opts:    X509VerifyOptions{RootCAs: []byte(rootCAFixture)},
+			wantErr: "unable to decode X.509 signature: not an armored CMS signature",
+		},
+		{
+			name:    "Missing signature",
+			payload: []byte(encodedX509CommitFixture),
+			opts:    X509VerifyOptions{RootCAs: []byte(rootCAFixture)},
+			wantErr: "unable to verify payload as the provided signature is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := verifyX509Signature(tt.sig, tt.payload, tt.opts)
+			if tt.wantErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+				g.Expect(got).To(BeNil())
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got.Format).To(Equal(SignatureFormatX509))
+			g.Expect(got.Identity).To(Equal(tt.want))
+			g.Expect(got.SignedAt).ToNot(BeZero())
+		})
+	}
+}
+
+func TestIsX509Signature(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsX509Signature(signatureX509CommitFixture)).To(BeTrue())
+	g.Expect(IsX509Signature(signatureSSHCommitFixture)).To(BeFalse())
+	g.Expect(IsX509Signature("")).To(BeFalse())
+}
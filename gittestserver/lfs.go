@@ -0,0 +1,183 @@
+/*
+Copyright 2023 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gittestserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	securefilepath "github.com/cyphar/filepath-securejoin"
+)
+
+const (
+	// lfsPathSegment separates the repository path from the Git LFS API
+	// path in a request URL.
+	lfsPathSegment = "/info/lfs/objects"
+	// lfsMediaType is the media type of the Git LFS batch API.
+	lfsMediaType = "application/vnd.git-lfs+json"
+)
+
+// LFS enables a minimal Git LFS server, supporting downloads using the
+// batch API and the basic transfer adapter. Objects can be added using
+// AddLFSObject. Use before calling StartHTTP or StartHTTPS.
+func (s *GitServer) LFS() *GitServer {
+	s.lfs = true
+	return s
+}
+
+// AddLFSObject stores the given content as a Git LFS object of the
+// repository at repoPath. It returns the content of the pointer file to
+// commit to the repository, to refer to the object.
+func (s *GitServer) AddLFSObject(repoPath string, content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	oid := hex.EncodeToString(sum[:])
+
+	objPath, err := s.lfsObjectPath(repoPath, oid)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(filepath.Dir(objPath), 0o755); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(objPath, content, 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(content)), nil
+}
+
+// lfsObjectPath returns the path of the Git LFS object with the given oid
+// of the repository at repoPath.
+func (s *GitServer) lfsObjectPath(repoPath, oid string) (string, error) {
+	if len(oid) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid oid '%s'", oid)
+	}
+	return securefilepath.SecureJoin(s.Root(), filepath.Join(repoPath, "lfs", "objects", oid[0:2], oid[2:4], oid))
+}
+
+type lfsObject struct {
+	Oid     string                `json:"oid"`
+	Size    int64                 `json:"size"`
+	Actions map[string]*lfsAction `json:"actions,omitempty"`
+	Error   *lfsError             `json:"error,omitempty"`
+}
+
+type lfsAction struct {
+	Href string `json:"href"`
+}
+
+type lfsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsHandler serves the Git LFS API requests, and passes all other
+// requests to the given handler.
+func (s *GitServer) lfsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repoPath, apiPath, ok := strings.Cut(r.URL.Path, lfsPathSegment)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The Git LFS client appends ".git" to the repository URL if it does
+		// not have the suffix already.
+		repoPath = strings.TrimPrefix(repoPath, "/")
+		if _, err := os.Stat(filepath.Join(s.Root(), repoPath)); err != nil {
+			repoPath = strings.TrimSuffix(repoPath, ".git")
+		}
+
+		if s.config.Auth {
+			username, password, _ := r.BasicAuth()
+			if username != s.username || password != s.password {
+				w.Header().Set("LFS-Authenticate", `Basic realm="Git LFS"`)
+				writeLFSError(w, http.StatusUnauthorized, "invalid credentials")
+				return
+			}
+		}
+
+		switch {
+		case apiPath == "/batch" && r.Method == http.MethodPost:
+			s.serveLFSBatch(w, r, repoPath)
+		case strings.HasPrefix(apiPath, "/") && r.Method == http.MethodGet:
+			objPath, err := s.lfsObjectPath(repoPath, strings.TrimPrefix(apiPath, "/"))
+			if err != nil {
+				writeLFSError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			http.ServeFile(w, r, objPath)
+		default:
+			writeLFSError(w, http.StatusNotFound, "not found")
+		}
+	})
+}
+
+// serveLFSBatch serves a Git LFS batch API request for the repository at
+// repoPath. Only the download operation is supported.
+func (s *GitServer) serveLFSBatch(w http.ResponseWriter, r *http.Request, repoPath string) {
+	var req struct {
+		Operation string      `json:"operation"`
+		Objects   []lfsObject `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeLFSError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if req.Operation != "download" {
+		writeLFSError(w, http.StatusUnprocessableEntity, fmt.Sprintf("unsupported operation '%s'", req.Operation))
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	objects := make([]lfsObject, 0, len(req.Objects))
+	for _, o := range req.Objects {
+		obj := lfsObject{Oid: o.Oid, Size: o.Size}
+		objPath, err := s.lfsObjectPath(repoPath, o.Oid)
+		if err == nil {
+			_, err = os.Stat(objPath)
+		}
+		if err != nil {
+			obj.Error = &lfsError{Code: http.StatusNotFound, Message: "object does not exist"}
+		} else {
+			obj.Actions = map[string]*lfsAction{
+				"download": {Href: fmt.Sprintf("%s://%s/%s%s/%s", scheme, r.Host, repoPath, lfsPathSegment, o.Oid)},
+			}
+		}
+		objects = append(objects, obj)
+	}
+
+	w.Header().Set("Content-Type", lfsMediaType)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"transfer": "basic",
+		"objects":  objects,
+	})
+}
+
+func writeLFSError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", lfsMediaType)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -24,8 +24,10 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -76,6 +78,10 @@ func NewGitServer(docroot string) *GitServer {
 }
 
 // WithSSHConfig sets the ssh.ServerConfig for the SSH Server.
+// If the config has a PublicKeyCallback, it is used to authenticate
+// clients instead of the default of accepting any public key. For
+// example, the Authenticate method of an ssh.CertChecker can be used to
+// only accept user certificates signed by a certain CA.
 func (g *GitServer) WithSSHConfig(cfg *ssh.ServerConfig) *GitServer {
 	g.sshServerConfig = cfg
 	return g
@@ -94,6 +100,9 @@ type GitServer struct {
 	// Set these to configure HTTP auth
 	username, password string
 	httpMiddlewares    []HTTPMiddleware
+	objectFormat       string
+	lfs                bool
+	clientCA           []byte
 }
 
 // AddHTTPMiddlewares adds http middlewares to the git server.
@@ -145,6 +154,14 @@ func (s *GitServer) Auth(username, password string) *GitServer {
 	return s
 }
 
+// RequireClientCert configures the HTTPS server to require a TLS client
+// certificate signed by the given PEM encoded CA. Use before calling
+// StartHTTPS.
+func (s *GitServer) RequireClientCert(ca []byte) *GitServer {
+	s.clientCA = ca
+	return s
+}
+
 // StartHTTP starts a new HTTP git server with the current configuration.
 func (s *GitServer) StartHTTP() error {
 	s.StopHTTP()
@@ -157,7 +174,11 @@ func (s *GitServer) StartHTTP() error {
 	if err := service.Setup(); err != nil {
 		return err
 	}
-	handler := buildHTTPHandler(service, s.httpMiddlewares...)
+	var handler http.Handler = service
+	if s.lfs {
+		handler = s.lfsHandler(handler)
+	}
+	handler = buildHTTPHandler(handler, s.httpMiddlewares...)
 	s.httpServer = httptest.NewServer(handler)
 	return nil
 }
@@ -174,7 +195,11 @@ func (s *GitServer) StartHTTPS(cert, key, ca []byte, serverName string) error {
 	if err := service.Setup(); err != nil {
 		return err
 	}
-	handler := buildHTTPHandler(service, s.httpMiddlewares...)
+	var handler http.Handler = service
+	if s.lfs {
+		handler = s.lfsHandler(handler)
+	}
+	handler = buildHTTPHandler(handler, s.httpMiddlewares...)
 	s.httpServer = httptest.NewUnstartedServer(handler)
 
 	config := tls.Config{}
@@ -190,6 +215,15 @@ func (s *GitServer) StartHTTPS(cert, key, ca []byte, serverName string) error {
 	config.RootCAs = cp
 
 	config.ServerName = serverName
+
+	if len(s.clientCA) > 0 {
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(s.clientCA) {
+			return fmt.Errorf("unable to append client CA to certificate pool")
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
 	s.httpServer.TLS = &config
 
 	s.httpServer.StartTLS()
@@ -235,9 +269,23 @@ func (s *GitServer) ListenSSH() error {
 		// This is where authentication would happen, when needed.
 		s.sshServer.PublicKeyLookupFunc = publicKeyLookupFunc
 
+		// gitkit overwrites the client authentication settings of the
+		// config while setting up the server, restore the callback of
+		// the config afterwards.
+		var publicKeyCallback func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error)
+		if s.sshServerConfig != nil {
+			publicKeyCallback = s.sshServerConfig.PublicKeyCallback
+		}
+
 		// :0 should result in an OS assigned free port; 127.0.0.1
 		// forces the lowest common denominator of TCPv4 on localhost.
-		return s.sshServer.Listen("127.0.0.1:0")
+		if err := s.sshServer.Listen("127.0.0.1:0"); err != nil {
+			return err
+		}
+		if publicKeyCallback != nil {
+			s.sshServerConfig.PublicKeyCallback = publicKeyCallback
+			s.sshServerConfig.NoClientAuth = false
+		}
 	}
 	return nil
 }
@@ -277,6 +325,16 @@ func (s *GitServer) ReadOnly(readOnly bool) *GitServer {
 	return s
 }
 
+// ObjectFormat sets the object format (hash algorithm) of the repositories
+// created by InitRepo, for example "sha256". Repositories with an object
+// format other than "sha1" are initialized using the git binary, as go-git
+// does not support them. InitRepo returns an error if the git binary can not
+// be found in PATH.
+func (s *GitServer) ObjectFormat(format string) *GitServer {
+	s.objectFormat = format
+	return s
+}
+
 // Root returns the repositories root directory.
 func (s *GitServer) Root() string {
 	return s.config.Dir
@@ -329,10 +387,19 @@ func (s *GitServer) InitRepo(fixture, branch, repoPath string) error {
 		return err
 	}
 
+	if s.objectFormat != "" && s.objectFormat != "sha1" {
+		return s.initRepoWithGit(fixture, branch, localRepo)
+	}
+
 	_, err = gogit.PlainInit(localRepo, true)
 	if err != nil {
 		return err
 	}
+	// Unlike git, go-git does not create the hooks directory, which is
+	// required for InstallUpdateHook.
+	if err = os.MkdirAll(filepath.Join(localRepo, "hooks"), 0o755); err != nil {
+		return err
+	}
 
 	// Create a new repo with the provided fixture. This creates a repo with
 	// default branch as "master".
@@ -382,6 +449,74 @@ func (s *GitServer) InitRepo(fixture, branch, repoPath string) error {
 	})
 }
 
+// initRepoWithGit initializes a bare repository at localRepo with the
+// configured object format using the git binary, and pushes a commit with
+// the given fixture to the master and target branch.
+func (s *GitServer) initRepoWithGit(fixture, branch, localRepo string) error {
+	workDir, err := os.MkdirTemp("", "git-server-init-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	if _, err = exec.LookPath("git"); err != nil {
+		return fmt.Errorf("object format '%s' requires the git binary: %w", s.objectFormat, err)
+	}
+	if err = runGit("", "init", "--bare", "--object-format="+s.objectFormat, "--initial-branch=master", localRepo); err != nil {
+		return err
+	}
+	if err = runGit(workDir, "init", "--object-format="+s.objectFormat, "--initial-branch=master"); err != nil {
+		return err
+	}
+
+	if err = filepath.Walk(fixture, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(workDir, path[len(fixture):])
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, fileBytes, info.Mode())
+	}); err != nil {
+		return err
+	}
+
+	if err = runGit(workDir, "add", "."); err != nil {
+		return err
+	}
+	if err = runGit(workDir, "commit", "-m", "Fixtures from "+fixture); err != nil {
+		return err
+	}
+	if branch != "master" {
+		if err = runGit(workDir, "branch", branch); err != nil {
+			return err
+		}
+	}
+	return runGit(workDir, "push", localRepo, "refs/heads/*:refs/heads/*")
+}
+
+// runGit runs the git binary with the given arguments in dir. The global
+// and system git configuration are ignored, so that the result does not
+// depend on the configuration of the user running the tests.
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Testbot", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Testbot", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
 func commitFromFixture(repo *gogit.Repository, fixture string) error {
 	working, err := repo.Worktree()
 	if err != nil {
@@ -1,9 +1,24 @@
 package gittestserver
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -11,6 +26,7 @@ import (
 
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestCreateSSHServer(t *testing.T) {
@@ -52,6 +68,82 @@ func TestListenSSH(t *testing.T) {
 	}
 }
 
+func TestListenSSH_certificate(t *testing.T) {
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		},
+	}
+
+	srv, err := NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srv.Root())
+	srv.KeyDir(srv.Root())
+	srv.WithSSHConfig(&ssh.ServerConfig{PublicKeyCallback: checker.Authenticate})
+	if err = srv.ListenSSH(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		srv.StartSSH()
+	}()
+	defer srv.StopSSH()
+
+	u, err := url.Parse(srv.SSHAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := func(signer ssh.Signer) error {
+		client, err := ssh.Dial("tcp", u.Host, &ssh.ClientConfig{
+			User:            "git",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		if err != nil {
+			return err
+		}
+		return client.Close()
+	}
+
+	if err = dial(signer); err == nil {
+		t.Error("expected authentication without certificate to fail")
+	}
+
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"git"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err = cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dial(certSigner); err != nil {
+		t.Errorf("expected authentication with certificate to succeed, got: %v", err)
+	}
+}
+
 func TestHTTPServer(t *testing.T) {
 	testUsername := "foo"
 	testPassword := "bar"
@@ -112,6 +204,104 @@ func TestHTTPSServer(t *testing.T) {
 	}
 }
 
+func TestHTTPSServer_RequireClientCert(t *testing.T) {
+	srv, err := NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srv.Root())
+
+	ca, cert, key := generateCertificates(t)
+	if err = srv.InitRepo("testdata/git/repo1", "master", "test.git"); err != nil {
+		t.Fatal(err)
+	}
+	srv.RequireClientCert(ca)
+	if err := srv.StartHTTPS(cert, key, ca, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopHTTP()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(ca)
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: certs},
+		}}
+		res, err := client.Get(srv.HTTPAddress() + "/test.git/info/refs?service=git-upload-pack")
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status code %d", res.StatusCode)
+		}
+		return nil
+	}
+
+	if err = get(); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+	keyPair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = get(keyPair); err != nil {
+		t.Errorf("expected request with client certificate to succeed, got: %v", err)
+	}
+}
+
+// generateCertificates returns a PEM encoded CA certificate, and a
+// certificate and key signed by it for both server and client
+// authentication on 127.0.0.1.
+func generateCertificates(t *testing.T) (ca, cert, key []byte) {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate, caTemplate, &certKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
 func TestInitRepo(t *testing.T) {
 	repoPath := "bar/test-reponame"
 	initBranch := "test-branch"
@@ -196,6 +386,53 @@ func TestInitRepo(t *testing.T) {
 	}
 }
 
+func TestInitRepo_ObjectFormat(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not found in PATH")
+	}
+
+	repoPath := "bar/test-reponame"
+	initBranch := "test-branch"
+
+	srv, err := NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srv.Root())
+	srv.ObjectFormat("sha256")
+	if err = srv.StartHTTP(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopHTTP()
+
+	// Initialize a repo.
+	err = srv.InitRepo("testdata/git/repo1", initBranch, repoPath)
+	if err != nil {
+		t.Fatalf("failed to initialize repo: %v", err)
+	}
+
+	// Clone the branch with the git binary, as go-git does not support
+	// SHA-256 on the wire.
+	cloneDir := t.TempDir()
+	if err = runGit("", "clone", "--branch", initBranch, srv.HTTPAddress()+"/"+repoPath, cloneDir); err != nil {
+		t.Fatalf("failed to clone repo: %v", err)
+	}
+
+	// Check file from clone.
+	if _, err := os.Stat(filepath.Join(cloneDir, "foo.txt")); os.IsNotExist(err) {
+		t.Error("expected foo.txt to exist")
+	}
+
+	// Check the object format of the clone.
+	out, err := exec.Command("git", "-C", cloneDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+	if hash := strings.TrimSpace(string(out)); len(hash) != 64 {
+		t.Errorf("expected SHA-256 hash, got %q", hash)
+	}
+}
+
 func TestGitServer_AddHTTPMiddlewares(t *testing.T) {
 	repoPath := "bar/test-reponame"
 	initBranch := "test-branch"
@@ -236,3 +473,80 @@ func TestGitServer_AddHTTPMiddlewares(t *testing.T) {
 		t.Errorf("expected error status code 500, got: %v", err)
 	}
 }
+
+func TestLFS(t *testing.T) {
+	srv, err := NewTempGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srv.Root())
+	srv.Auth("foo", "bar").LFS()
+	if err = srv.StartHTTP(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopHTTP()
+
+	content := []byte("large file content")
+	pointer, err := srv.AddLFSObject("repo", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(pointer, "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "oid sha256:") || lines[2] != fmt.Sprintf("size %d", len(content)) {
+		t.Fatalf("unexpected pointer: %q", pointer)
+	}
+	oid := strings.TrimPrefix(lines[1], "oid sha256:")
+
+	batch := func(username, password string) *http.Response {
+		body := fmt.Sprintf(`{"operation":"download","objects":[{"oid":%q,"size":%d}]}`, oid, len(content))
+		req, err := http.NewRequest(http.MethodPost, srv.HTTPAddress()+"/repo.git/info/lfs/objects/batch", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(username, password)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := batch("foo", "invalid")
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status code %d, got %d", http.StatusUnauthorized, res.StatusCode)
+	}
+
+	res = batch("foo", "bar")
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	var resp struct {
+		Objects []lfsObject `json:"objects"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Objects) != 1 || resp.Objects[0].Actions["download"] == nil {
+		t.Fatalf("expected a download action, got %+v", resp.Objects)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, resp.Objects[0].Actions["download"].Href, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("foo", "bar")
+	obj, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Body.Close()
+	got, err := io.ReadAll(obj.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("expected object content %q, got %q", content, got)
+	}
+}
@@ -1,19 +1,18 @@
 module github.com/fluxcd/pkg/sourceignore
 
-go 1.20
+go 1.23.0
 
 require (
-	github.com/go-git/go-git/v5 v5.8.1
+	github.com/go-git/go-git/v5 v5.16.2
 	gotest.tools v2.2.0+incompatible
 )
 
 require (
-	github.com/acomagu/bufpipe v1.0.4 // indirect
 	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
-	github.com/go-git/go-billy/v5 v5.4.1 // indirect
-	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/go-git/go-billy/v5 v5.6.2 // indirect
+	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/net v0.39.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 )